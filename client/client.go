@@ -0,0 +1,263 @@
+// Package client plays bots on a game server. A bot implements Agent, the
+// Runner connects to the server, creates the games, sends the moves of the
+// agent, retries calls while the server is unavailable and keeps statistics.
+// Games can be played concurrently, every game gets an agent of its own,
+// state shared by the agents has to be guarded by them.
+package client
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/arenaio/woodhack2018/proto"
+)
+
+// Turn is what an agent knows of a game, the state is seen from the agent,
+// its fields are 1 and the fields of the opponent -1
+type Turn struct {
+	Game       int64 // id on the server
+	State      []int64
+	LastMove   int64 // of the game, -1 if there is none
+	LegalMoves []int64
+	Rules      *proto.Rules // sent with the first turn only, kept for the others
+	Seat       int64
+	Result     int64 // of the last move of the agent, ValidMove at the start
+}
+
+// Over is true if the result ends the game
+func (t *Turn) Over() bool {
+	return t.Result == proto.Won || t.Result == proto.Draw || t.Result == proto.Lost
+}
+
+// Swap is returned by Agent.Move to take over the position after the first
+// move of the opponent instead of moving, if the rules have the pie rule
+const Swap int64 = -1
+
+type Agent interface {
+	// Move returns the move to make in a turn or Swap, an error ends the game
+	Move(t *Turn) (int64, error)
+	// Observe is called with every move sent and the turn it led to, which
+	// is the last one of a game if it is over
+	Observe(move int64, t *Turn)
+}
+
+// Stats of the games played by a runner
+type Stats struct {
+	Games        int64
+	Won          int64
+	Draw         int64
+	Lost         int64
+	Moves        int64 // valid ones
+	InvalidMoves int64
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d games (won / draw / lost): %d / %d / %d", s.Games, s.Won, s.Draw, s.Lost)
+}
+
+func (s *Stats) add(g Game) {
+	s.Games++
+	switch g.Result {
+	case proto.Won:
+		s.Won++
+	case proto.Draw:
+		s.Draw++
+	case proto.Lost:
+		s.Lost++
+	}
+	s.Moves += g.Moves
+	s.InvalidMoves += g.InvalidMoves
+}
+
+// Game is the report of a finished game
+type Game struct {
+	ID           int64
+	Result       int64
+	Moves        int64 // valid ones
+	InvalidMoves int64
+	Duration     time.Duration
+}
+
+func (g Game) String() string {
+	result := map[int64]string{proto.Won: "won", proto.Draw: "draw", proto.Lost: "lost"}[g.Result]
+	return fmt.Sprintf("game %d %s after %d moves (%d invalid) in %s", g.ID, result, g.Moves, g.InvalidMoves, g.Duration)
+}
+
+type Runner struct {
+	Name      string
+	Opponent  string // only play against the bot with this name if set, Name for self-play
+	GameType  int64
+	Retries   int           // of calls failing because the server is unavailable
+	RetryWait time.Duration // doubled after every retry
+	LogEvery  int64         // games between logging the statistics, 0 never logs
+	LogGames  bool          // log the report of every game
+	// OnGame is called with the report of every game if set, concurrently if
+	// games are played concurrently
+	OnGame func(g Game)
+
+	m      sync.Mutex
+	stats  Stats
+	conn   *grpc.ClientConn
+	client proto.TicTacToeClient
+}
+
+// NewRunner connects to a server
+func NewRunner(address, name string, gameType int64) (*Runner, error) {
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect on port %s: %s", address, err)
+	}
+
+	return &Runner{
+		Name:      name,
+		GameType:  gameType,
+		Retries:   5,
+		RetryWait: 100 * time.Millisecond,
+		conn:      conn,
+		client:    proto.NewTicTacToeClient(conn),
+	}, nil
+}
+
+func (r *Runner) Close() error {
+	return r.conn.Close()
+}
+
+// Stats returns the statistics of all games played
+func (r *Runner) Stats() Stats {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.stats
+}
+
+// Run plays games one after another with an agent, forever if games is 0
+func (r *Runner) Run(ctx context.Context, agent Agent, games int64) error {
+	return r.RunConcurrent(ctx, func() Agent { return agent }, games, 1)
+}
+
+// RunConcurrent plays games with up to concurrency of them at the same time,
+// forever if games is 0. newAgent is called for every game, concurrently. The
+// first error stops all games and is returned.
+func (r *Runner) RunConcurrent(ctx context.Context, newAgent func() Agent, games int64, concurrency int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var started int64
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && (games == 0 || atomic.AddInt64(&started, 1) <= games) {
+				if _, err := r.Play(ctx, newAgent()); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// Play plays a game with an agent and returns its report
+func (r *Runner) Play(ctx context.Context, agent Agent) (Game, error) {
+	start := time.Now()
+	var stateResult *proto.StateResult
+	err := r.retry(func() (err error) {
+		stateResult, err = r.client.NewGame(ctx, &proto.New{GameType: r.GameType, Name: r.Name, Opponent: r.Opponent})
+		return err
+	})
+	if err != nil {
+		return Game{}, fmt.Errorf("creating game failed: %s", err)
+	}
+
+	id := stateResult.Id
+	rules := stateResult.Rules
+	g := Game{ID: id}
+	t := turn(stateResult, rules)
+	for !t.Over() {
+		move, err := agent.Move(t)
+		if err != nil {
+			return Game{}, err
+		}
+
+		action := &proto.Action{Id: id, Move: move}
+		if move == Swap {
+			action = &proto.Action{Id: id, Kind: proto.SwapAction}
+		}
+		err = r.retry(func() (err error) {
+			stateResult, err = r.client.Move(ctx, action)
+			return err
+		})
+		if err != nil {
+			return Game{}, fmt.Errorf("move %d in game %d failed: %s", move, id, err)
+		}
+
+		t = turn(stateResult, rules)
+		if t.Result == proto.InvalidMove {
+			g.InvalidMoves++
+		} else {
+			g.Moves++
+		}
+		agent.Observe(move, t)
+	}
+	g.Result = t.Result
+	g.Duration = time.Since(start)
+
+	r.m.Lock()
+	r.stats.add(g)
+	stats := r.stats
+	r.m.Unlock()
+
+	if r.LogGames {
+		log.Print(g)
+	}
+	if r.LogEvery > 0 && stats.Games%r.LogEvery == 0 {
+		log.Print(stats)
+	}
+	if r.OnGame != nil {
+		r.OnGame(g)
+	}
+
+	return g, nil
+}
+
+// retry calls f again while the server is unavailable, other errors could
+// have been caused by a call that reached the server
+func (r *Runner) retry(f func() error) error {
+	wait := r.RetryWait
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i >= r.Retries || status.Code(err) != codes.Unavailable {
+			return err
+		}
+
+		log.Printf("server unavailable, retrying in %s: %s", wait, err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func turn(s *proto.StateResult, rules *proto.Rules) *Turn {
+	return &Turn{
+		Game:       s.Id,
+		State:      s.State,
+		LastMove:   s.LastMove,
+		LegalMoves: s.LegalMoves,
+		Rules:      rules,
+		Seat:       s.Seat,
+		Result:     s.Result,
+	}
+}
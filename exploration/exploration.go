@@ -0,0 +1,250 @@
+// Package exploration decides how the learning players trade off trying moves
+// against playing the best known one.
+//
+// A Strategy turns the values of the legal moves into the probabilities of
+// playing them, controlled by a rate: the probability of a random move for
+// epsilon-greedy, the temperature for softmax and the exploration constant
+// for UCB. A rate of 0 always plays greedily. A Schedule changes the rate
+// over the moves or episodes played.
+package exploration
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Strategies selectable by name
+const (
+	EpsilonGreedy = "epsilon"
+	Softmax       = "softmax"
+	UCB           = "ucb"
+)
+
+// Schedules selectable by name
+const (
+	PerMove     = "move" // the rate is multiplied by the decay after every move
+	Linear      = "linear"
+	Exponential = "exponential"
+)
+
+type Strategy interface {
+	// Probabilities of playing the moves given their values and how often
+	// they were played
+	Probabilities(rate float64, moves []int64, value, visits func(int64) float64) []float64
+	String() string
+}
+
+// NewStrategy returns a strategy by name
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case EpsilonGreedy:
+		return epsilonGreedy{}, nil
+	case Softmax:
+		return softmax{}, nil
+	case UCB:
+		return ucb{}, nil
+	}
+	return nil, fmt.Errorf("unknown exploration strategy %s", name)
+}
+
+// Sample picks one of the moves by their probabilities
+func Sample(r *rand.Rand, moves []int64, probabilities []float64) int64 {
+	x := r.Float64()
+	for i, p := range probabilities {
+		x -= p
+		if x < 0 {
+			return moves[i]
+		}
+	}
+	return moves[len(moves)-1]
+}
+
+// best spreads the probability over the moves with the highest score
+func best(moves []int64, score func(int64) float64) []float64 {
+	probabilities := make([]float64, len(moves))
+	bestScore := -math.MaxFloat64
+	count := 0
+	for i, move := range moves {
+		s := score(move)
+		switch {
+		case s > bestScore:
+			bestScore = s
+			count = 1
+			for j := 0; j < i; j++ {
+				probabilities[j] = 0
+			}
+			probabilities[i] = 1
+		case s == bestScore:
+			count++
+			probabilities[i] = 1
+		}
+	}
+
+	for i := range probabilities {
+		probabilities[i] /= float64(count)
+	}
+	return probabilities
+}
+
+// epsilonGreedy plays a random move with the probability of the rate
+type epsilonGreedy struct{}
+
+func (epsilonGreedy) Probabilities(rate float64, moves []int64, value, visits func(int64) float64) []float64 {
+	probabilities := best(moves, value)
+	if rate <= 0 {
+		return probabilities
+	}
+
+	rate = math.Min(rate, 1)
+	for i := range probabilities {
+		probabilities[i] = (1-rate)*probabilities[i] + rate/float64(len(moves))
+	}
+	return probabilities
+}
+
+func (epsilonGreedy) String() string {
+	return EpsilonGreedy
+}
+
+// softmax plays moves by the exponential of their value divided by the rate
+// (Boltzmann exploration)
+type softmax struct{}
+
+func (softmax) Probabilities(rate float64, moves []int64, value, visits func(int64) float64) []float64 {
+	if rate <= 0 {
+		return best(moves, value)
+	}
+
+	values := make([]float64, len(moves))
+	max := -math.MaxFloat64
+	for i, move := range moves {
+		values[i] = value(move)
+		if values[i] > max {
+			max = values[i]
+		}
+	}
+
+	probabilities := make([]float64, len(moves))
+	total := 0.0
+	for i, v := range values {
+		probabilities[i] = math.Exp((v - max) / rate)
+		total += probabilities[i]
+	}
+	for i := range probabilities {
+		probabilities[i] /= total
+	}
+	return probabilities
+}
+
+func (softmax) String() string {
+	return Softmax
+}
+
+// ucb plays the move with the highest upper confidence bound, its value plus
+// the rate times sqrt(ln(visits of all moves) / visits of the move). Moves
+// never played come first.
+type ucb struct{}
+
+func (ucb) Probabilities(rate float64, moves []int64, value, visits func(int64) float64) []float64 {
+	if rate <= 0 {
+		return best(moves, value)
+	}
+
+	total := 0.0
+	for _, move := range moves {
+		total += visits(move)
+	}
+	logTotal := math.Log(total)
+
+	return best(moves, func(move int64) float64 {
+		n := visits(move)
+		if n == 0 {
+			return math.Inf(1)
+		}
+		return value(move) + rate*math.Sqrt(logTotal/n)
+	})
+}
+
+func (ucb) String() string {
+	return UCB
+}
+
+type Schedule interface {
+	// Episode returns the rate to start an episode with
+	Episode(rate float64, episode int64) float64
+	// Move returns the rate after a move
+	Move(rate float64) float64
+	String() string
+}
+
+// NewSchedule returns a schedule by name. The rate goes from start to end
+// within the episodes for linear schedules and is multiplied by the decay
+// every episode, but not below end, for exponential ones. Per move schedules
+// multiply the rate by the decay after every move.
+func NewSchedule(name string, start, end, decay float64, episodes int64) (Schedule, error) {
+	switch name {
+	case PerMove:
+		return perMove{decay}, nil
+	case Linear:
+		if episodes <= 0 {
+			return nil, fmt.Errorf("linear schedule over %d episodes", episodes)
+		}
+		return linear{start, end, episodes}, nil
+	case Exponential:
+		return exponential{start, end, decay}, nil
+	}
+	return nil, fmt.Errorf("unknown exploration schedule %s", name)
+}
+
+type perMove struct {
+	decay float64
+}
+
+func (s perMove) Episode(rate float64, episode int64) float64 {
+	return rate
+}
+
+func (s perMove) Move(rate float64) float64 {
+	return rate * s.decay
+}
+
+func (s perMove) String() string {
+	return fmt.Sprintf("%s decay %g", PerMove, s.decay)
+}
+
+type linear struct {
+	start, end float64
+	episodes   int64
+}
+
+func (s linear) Episode(rate float64, episode int64) float64 {
+	if episode >= s.episodes {
+		return s.end
+	}
+	return s.start + (s.end-s.start)*float64(episode)/float64(s.episodes)
+}
+
+func (s linear) Move(rate float64) float64 {
+	return rate
+}
+
+func (s linear) String() string {
+	return fmt.Sprintf("%s %g to %g in %d episodes", Linear, s.start, s.end, s.episodes)
+}
+
+type exponential struct {
+	start, end, decay float64
+}
+
+func (s exponential) Episode(rate float64, episode int64) float64 {
+	return math.Max(s.end, s.start*math.Pow(s.decay, float64(episode)))
+}
+
+func (s exponential) Move(rate float64) float64 {
+	return rate
+}
+
+func (s exponential) String() string {
+	return fmt.Sprintf("%s %g decay %g to %g", Exponential, s.start, s.decay, s.end)
+}
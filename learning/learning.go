@@ -0,0 +1,152 @@
+// Package learning holds what the Q-learning players share besides their
+// tables: the connection to a parameter server and the evaluation of a
+// trained player.
+//
+// Params collects the updates of an actor to push them to a parameter server
+// and pulls the values other actors changed into the local table. Evaluate
+// plays a number of games and logs the results with their 95% confidence
+// intervals.
+package learning
+
+import (
+	"log"
+	"math"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/qtable"
+)
+
+// Params is the connection to a parameter server holding the table shared
+// by many actors. The updates are applied locally right away and collected to
+// be pushed, the pulled values replace the local ones.
+type Params struct {
+	conn    *grpc.ClientConn
+	client  proto.QTableClient
+	updates []*proto.Update
+	games   int64 // finished since the last push
+	version int64 // of the last pull
+}
+
+// DialParams connects to a parameter server
+func DialParams(address string) (*Params, error) {
+	conn, err := grpc.Dial(
+		address,
+		grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(qtable.MaxMessageSize), grpc.MaxCallSendMsgSize(qtable.MaxMessageSize)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Params{
+		conn:   conn,
+		client: proto.NewQTableClient(conn),
+	}, nil
+}
+
+func (p *Params) Close() error {
+	return p.conn.Close()
+}
+
+// Add collects an update of the value of an action of a canonical state
+func (p *Params) Add(state string, action int64, target, step float64) {
+	p.updates = append(p.updates, &proto.Update{
+		State:  state,
+		Action: action,
+		Target: target,
+		Step:   step,
+	})
+}
+
+// Game counts a finished game, the server adds them to the games of its
+// checkpoints
+func (p *Params) Game() {
+	p.games++
+}
+
+// Sync pushes the collected updates together with the parameters of the
+// actor if there are any and pulls the values changed since the last pull
+// into the table
+func (p *Params) Sync(ctx context.Context, table qtable.Table, h qtable.Header) error {
+	if len(p.updates) > 0 || p.games > 0 {
+		_, err := p.client.Push(ctx, &proto.Updates{
+			Updates:          p.updates,
+			Games:            p.games,
+			ExplorationRate:  h.ExplorationRate,
+			ExplorationDecay: h.ExplorationDecay,
+			LearningRate:     h.LearningRate,
+			DiscountFactor:   h.DiscountFactor,
+		})
+		if err != nil {
+			return err
+		}
+		p.updates = nil
+		p.games = 0
+	}
+
+	entries, err := p.client.Pull(ctx, &proto.Version{Version: p.version})
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries.Entries {
+		actionTable := make(map[int64]float64, len(entry.Values))
+		for action, value := range entry.Values {
+			actionTable[int64(action)] = value
+		}
+		table[entry.State] = actionTable
+	}
+	p.version = entries.Version
+
+	return nil
+}
+
+// Evaluate plays games with up to concurrency of them at the same time and
+// logs the results with their 95% confidence intervals, the agents should
+// play greedily without learning
+func Evaluate(ctx context.Context, runner *client.Runner, newAgent func() client.Agent, games, concurrency int) error {
+	if err := runner.RunConcurrent(ctx, newAgent, int64(games), concurrency); err != nil {
+		return err
+	}
+	stats := runner.Stats()
+
+	opponent := runner.Opponent
+	if len(opponent) == 0 {
+		opponent = "any opponent"
+	}
+	log.Printf("%d games against %s", games, opponent)
+	outcomes := []struct {
+		name  string
+		count int64
+	}{
+		{"won", stats.Won},
+		{"draw", stats.Draw},
+		{"lost", stats.Lost},
+	}
+	for _, outcome := range outcomes {
+		lower, upper := wilson(outcome.count, int64(games))
+		log.Printf(
+			"%s\t%d\t%.1f%% (%.1f%% - %.1f%%)",
+			outcome.name,
+			outcome.count,
+			float64(outcome.count)/float64(games)*100,
+			lower*100,
+			upper*100,
+		)
+	}
+	return nil
+}
+
+// wilson returns the 95% Wilson score interval of a proportion
+func wilson(count, total int64) (float64, float64) {
+	const z = 1.96
+	n := float64(total)
+	p := float64(count) / n
+
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return center - margin, center + margin
+}
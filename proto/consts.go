@@ -8,5 +8,26 @@ const ValidMove int64 = 0
 const Draw int64 = 1
 const Won int64 = 2
 
+// Action.Kind, a swap takes over the position after the first move of the
+// opponent if the pie rule is enabled, Action.Move is ignored then
+const MoveAction int64 = 0
+const SwapAction int64 = 1
+
 const RegularTicTacToe int64 = 0
 const UltimateTicTacToe int64 = 1
+
+// ConnectFour is played on a 6x7 grid, Action.Move is the column (0-6) and the
+// state is returned row by row starting with the top row
+const ConnectFour int64 = 2
+
+// MisereTicTacToe is regular tic-tac-toe, but completing a line loses
+const MisereTicTacToe int64 = 3
+
+// Notakto is played on multiple 3x3 boards (9 fields each), both players place
+// the same pieces (1), a board with a line is dead and killing the last board
+// loses
+const Notakto int64 = 4
+
+// Qubic is tic-tac-toe on a 4x4x4 cube, the 64 fields are numbered
+// layer*16 + row*4 + column and four in a line win
+const Qubic int64 = 5
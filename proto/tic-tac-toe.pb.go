@@ -26,6 +26,7 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 type New struct {
 	GameType             int64    `protobuf:"varint,1,opt,name=gameType,proto3" json:"gameType,omitempty"`
 	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Opponent             string   `protobuf:"bytes,3,opt,name=opponent,proto3" json:"opponent,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -35,7 +36,7 @@ func (m *New) Reset()         { *m = New{} }
 func (m *New) String() string { return proto.CompactTextString(m) }
 func (*New) ProtoMessage()    {}
 func (*New) Descriptor() ([]byte, []int) {
-	return fileDescriptor_tic_tac_toe_e3f9d7f670a323a2, []int{0}
+	return fileDescriptor_tic_tac_toe_c0216aed9f36a3ed, []int{0}
 }
 func (m *New) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_New.Unmarshal(m, b)
@@ -69,11 +70,21 @@ func (m *New) GetName() string {
 	return ""
 }
 
+func (m *New) GetOpponent() string {
+	if m != nil {
+		return m.Opponent
+	}
+	return ""
+}
+
 type StateResult struct {
 	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	State                []int64  `protobuf:"varint,2,rep,packed,name=state,proto3" json:"state,omitempty"`
 	Result               int64    `protobuf:"varint,3,opt,name=result,proto3" json:"result,omitempty"`
 	LastMove             int64    `protobuf:"varint,4,opt,name=lastMove,proto3" json:"lastMove,omitempty"`
+	Rules                *Rules   `protobuf:"bytes,5,opt,name=rules,proto3" json:"rules,omitempty"`
+	Seat                 int64    `protobuf:"varint,6,opt,name=seat,proto3" json:"seat,omitempty"`
+	LegalMoves           []int64  `protobuf:"varint,7,rep,packed,name=legalMoves,proto3" json:"legalMoves,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -83,7 +94,7 @@ func (m *StateResult) Reset()         { *m = StateResult{} }
 func (m *StateResult) String() string { return proto.CompactTextString(m) }
 func (*StateResult) ProtoMessage()    {}
 func (*StateResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_tic_tac_toe_e3f9d7f670a323a2, []int{1}
+	return fileDescriptor_tic_tac_toe_c0216aed9f36a3ed, []int{1}
 }
 func (m *StateResult) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StateResult.Unmarshal(m, b)
@@ -131,9 +142,31 @@ func (m *StateResult) GetLastMove() int64 {
 	return 0
 }
 
+func (m *StateResult) GetRules() *Rules {
+	if m != nil {
+		return m.Rules
+	}
+	return nil
+}
+
+func (m *StateResult) GetSeat() int64 {
+	if m != nil {
+		return m.Seat
+	}
+	return 0
+}
+
+func (m *StateResult) GetLegalMoves() []int64 {
+	if m != nil {
+		return m.LegalMoves
+	}
+	return nil
+}
+
 type Action struct {
 	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Move                 int64    `protobuf:"varint,2,opt,name=move,proto3" json:"move,omitempty"`
+	Kind                 int64    `protobuf:"varint,3,opt,name=kind,proto3" json:"kind,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -143,7 +176,7 @@ func (m *Action) Reset()         { *m = Action{} }
 func (m *Action) String() string { return proto.CompactTextString(m) }
 func (*Action) ProtoMessage()    {}
 func (*Action) Descriptor() ([]byte, []int) {
-	return fileDescriptor_tic_tac_toe_e3f9d7f670a323a2, []int{2}
+	return fileDescriptor_tic_tac_toe_c0216aed9f36a3ed, []int{2}
 }
 func (m *Action) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Action.Unmarshal(m, b)
@@ -177,10 +210,88 @@ func (m *Action) GetMove() int64 {
 	return 0
 }
 
+func (m *Action) GetKind() int64 {
+	if m != nil {
+		return m.Kind
+	}
+	return 0
+}
+
+type Rules struct {
+	TiebreakByCount      bool     `protobuf:"varint,1,opt,name=tiebreakByCount,proto3" json:"tiebreakByCount,omitempty"`
+	ForcedMove           bool     `protobuf:"varint,2,opt,name=forcedMove,proto3" json:"forcedMove,omitempty"`
+	DrawCountsForBoth    bool     `protobuf:"varint,3,opt,name=drawCountsForBoth,proto3" json:"drawCountsForBoth,omitempty"`
+	Depth                int64    `protobuf:"varint,4,opt,name=depth,proto3" json:"depth,omitempty"`
+	PieRule              bool     `protobuf:"varint,5,opt,name=pieRule,proto3" json:"pieRule,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Rules) Reset()         { *m = Rules{} }
+func (m *Rules) String() string { return proto.CompactTextString(m) }
+func (*Rules) ProtoMessage()    {}
+func (*Rules) Descriptor() ([]byte, []int) {
+	return fileDescriptor_tic_tac_toe_c0216aed9f36a3ed, []int{3}
+}
+func (m *Rules) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Rules.Unmarshal(m, b)
+}
+func (m *Rules) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Rules.Marshal(b, m, deterministic)
+}
+func (dst *Rules) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Rules.Merge(dst, src)
+}
+func (m *Rules) XXX_Size() int {
+	return xxx_messageInfo_Rules.Size(m)
+}
+func (m *Rules) XXX_DiscardUnknown() {
+	xxx_messageInfo_Rules.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Rules proto.InternalMessageInfo
+
+func (m *Rules) GetTiebreakByCount() bool {
+	if m != nil {
+		return m.TiebreakByCount
+	}
+	return false
+}
+
+func (m *Rules) GetForcedMove() bool {
+	if m != nil {
+		return m.ForcedMove
+	}
+	return false
+}
+
+func (m *Rules) GetDrawCountsForBoth() bool {
+	if m != nil {
+		return m.DrawCountsForBoth
+	}
+	return false
+}
+
+func (m *Rules) GetDepth() int64 {
+	if m != nil {
+		return m.Depth
+	}
+	return 0
+}
+
+func (m *Rules) GetPieRule() bool {
+	if m != nil {
+		return m.PieRule
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterType((*New)(nil), "proto.New")
 	proto.RegisterType((*StateResult)(nil), "proto.StateResult")
 	proto.RegisterType((*Action)(nil), "proto.Action")
+	proto.RegisterType((*Rules)(nil), "proto.Rules")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -289,24 +400,33 @@ var _TicTacToe_serviceDesc = grpc.ServiceDesc{
 }
 
 func init() {
-	proto.RegisterFile("proto/tic-tac-toe.proto", fileDescriptor_tic_tac_toe_e3f9d7f670a323a2)
-}
-
-var fileDescriptor_tic_tac_toe_e3f9d7f670a323a2 = []byte{
-	// 238 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x8f, 0x41, 0x4b, 0xc3, 0x40,
-	0x10, 0x85, 0xcd, 0x6e, 0x1a, 0xed, 0x88, 0x1e, 0x06, 0xd1, 0xa5, 0xa7, 0x90, 0x53, 0xa0, 0xb6,
-	0x82, 0xe2, 0x0f, 0xf0, 0xe4, 0xc9, 0x1c, 0x62, 0xfe, 0xc0, 0xba, 0x1d, 0xca, 0x42, 0xd3, 0x2d,
-	0xcd, 0x68, 0xf0, 0xdf, 0x4b, 0x66, 0x57, 0x11, 0xf4, 0xb4, 0xef, 0xbd, 0x79, 0xc3, 0xb7, 0x03,
-	0x37, 0x87, 0x63, 0xe0, 0x70, 0xc7, 0xde, 0xad, 0xd8, 0xba, 0x15, 0x07, 0x5a, 0x4b, 0x82, 0x33,
-	0x79, 0xaa, 0x47, 0xd0, 0x0d, 0x8d, 0xb8, 0x80, 0xb3, 0xad, 0xed, 0xa9, 0xfb, 0x3c, 0x90, 0xc9,
-	0xca, 0xac, 0xd6, 0xed, 0x8f, 0x47, 0x84, 0x7c, 0x6f, 0x7b, 0x32, 0xaa, 0xcc, 0xea, 0x79, 0x2b,
-	0xba, 0xda, 0xc2, 0xf9, 0x2b, 0x5b, 0xa6, 0x96, 0x86, 0xf7, 0x1d, 0xe3, 0x25, 0x28, 0xbf, 0x49,
-	0x8b, 0xca, 0x6f, 0xf0, 0x0a, 0x66, 0xc3, 0x34, 0x36, 0xaa, 0xd4, 0xb5, 0x6e, 0xa3, 0xc1, 0x6b,
-	0x28, 0x8e, 0xd2, 0x37, 0x5a, 0x9a, 0xc9, 0x4d, 0xf0, 0x9d, 0x1d, 0xf8, 0x25, 0x7c, 0x90, 0xc9,
-	0x23, 0xfc, 0xdb, 0x57, 0xb7, 0x50, 0x3c, 0x39, 0xf6, 0x61, 0xff, 0x87, 0x81, 0x90, 0xf7, 0xd3,
-	0x86, 0x92, 0x44, 0xf4, 0x3d, 0xc1, 0xbc, 0xf3, 0xae, 0xb3, 0xae, 0x0b, 0x84, 0x4b, 0x38, 0x6d,
-	0x68, 0x7c, 0xb6, 0x3d, 0x21, 0xc4, 0xa3, 0xd7, 0x0d, 0x8d, 0x0b, 0x4c, 0xfa, 0xd7, 0xff, 0xab,
-	0x13, 0x5c, 0x42, 0x3e, 0xf1, 0xf0, 0x22, 0x4d, 0x23, 0xf4, 0xff, 0xf2, 0x5b, 0x21, 0xe1, 0xc3,
-	0x57, 0x00, 0x00, 0x00, 0xff, 0xff, 0xf1, 0xad, 0xae, 0xf4, 0x5d, 0x01, 0x00, 0x00,
+	proto.RegisterFile("proto/tic-tac-toe.proto", fileDescriptor_tic_tac_toe_c0216aed9f36a3ed)
+}
+
+var fileDescriptor_tic_tac_toe_c0216aed9f36a3ed = []byte{
+	// 383 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x51, 0x5b, 0x8e, 0xd3, 0x40,
+	0x10, 0xc4, 0xcf, 0x78, 0x7b, 0x79, 0x88, 0x16, 0x82, 0xd1, 0x7e, 0x20, 0xcb, 0x5f, 0x96, 0x96,
+	0x5d, 0xa4, 0xe5, 0x02, 0x10, 0x24, 0xf8, 0x4a, 0x24, 0x06, 0x5f, 0x60, 0x62, 0x37, 0xc9, 0x28,
+	0x8e, 0xc7, 0xb2, 0x27, 0xb1, 0x72, 0x2a, 0x8e, 0xc0, 0xd5, 0xd0, 0xb4, 0x9d, 0x28, 0x22, 0x7c,
+	0xb9, 0xaa, 0xba, 0xdd, 0x5d, 0x53, 0x0d, 0xef, 0xda, 0xce, 0x58, 0xf3, 0xd1, 0xea, 0xf2, 0xc1,
+	0xaa, 0xf2, 0xc1, 0x1a, 0x7a, 0x64, 0x05, 0x23, 0xfe, 0x64, 0x3f, 0x20, 0x58, 0xd2, 0x80, 0x77,
+	0x90, 0xac, 0xd5, 0x8e, 0x8a, 0x63, 0x4b, 0xc2, 0x4b, 0xbd, 0x3c, 0x90, 0x67, 0x8e, 0x08, 0x61,
+	0xa3, 0x76, 0x24, 0xfc, 0xd4, 0xcb, 0x6f, 0x24, 0x63, 0xd7, 0x6f, 0xda, 0xd6, 0x34, 0xd4, 0x58,
+	0x11, 0xb0, 0x7e, 0xe6, 0xd9, 0x1f, 0x0f, 0x6e, 0x7f, 0x5a, 0x65, 0x49, 0x52, 0xbf, 0xaf, 0x2d,
+	0xbe, 0x04, 0x5f, 0x57, 0xd3, 0x54, 0x5f, 0x57, 0xf8, 0x06, 0xa2, 0xde, 0x95, 0x85, 0x9f, 0x06,
+	0x79, 0x20, 0x47, 0x82, 0x6f, 0x21, 0xee, 0xb8, 0x9f, 0xe7, 0x05, 0x72, 0x62, 0x6e, 0x53, 0xad,
+	0x7a, 0xbb, 0x30, 0x07, 0x12, 0xe1, 0xe8, 0xec, 0xc4, 0x31, 0x83, 0xa8, 0xdb, 0xd7, 0xd4, 0x8b,
+	0x28, 0xf5, 0xf2, 0xdb, 0xa7, 0xe7, 0xe3, 0xd3, 0x1e, 0xa5, 0xd3, 0xe4, 0x58, 0x72, 0xee, 0x7b,
+	0x52, 0x56, 0xc4, 0xfc, 0x2f, 0x63, 0x7c, 0x0f, 0x50, 0xd3, 0x5a, 0xd5, 0x6e, 0x48, 0x2f, 0x66,
+	0x6c, 0xe3, 0x42, 0xc9, 0x3e, 0x43, 0xfc, 0xa5, 0xb4, 0xda, 0x34, 0x57, 0xde, 0x11, 0xc2, 0x9d,
+	0x39, 0x8c, 0x59, 0x04, 0x92, 0xb1, 0xd3, 0xb6, 0xba, 0xa9, 0x26, 0xdf, 0x8c, 0xb3, 0xdf, 0x1e,
+	0x44, 0x6c, 0x03, 0x73, 0x78, 0x65, 0x35, 0xad, 0x3a, 0x52, 0xdb, 0xf9, 0xf1, 0xab, 0xd9, 0x37,
+	0x96, 0xc7, 0x25, 0xf2, 0x5f, 0xd9, 0xb9, 0xfa, 0x65, 0xba, 0x92, 0xaa, 0xc5, 0x69, 0x43, 0x22,
+	0x2f, 0x14, 0xfc, 0x00, 0xaf, 0xab, 0x4e, 0x0d, 0xdc, 0xdc, 0x7f, 0x33, 0xdd, 0xdc, 0xd8, 0x0d,
+	0x2f, 0x4d, 0xe4, 0x75, 0xc1, 0xa5, 0x5c, 0x51, 0x6b, 0x37, 0x53, 0x68, 0x23, 0x41, 0x01, 0xb3,
+	0x56, 0x93, 0x73, 0xc6, 0x99, 0x25, 0xf2, 0x44, 0x9f, 0x08, 0x6e, 0x0a, 0x5d, 0x16, 0xaa, 0x2c,
+	0x0c, 0xe1, 0x3d, 0xcc, 0x96, 0x34, 0x7c, 0x77, 0x97, 0x86, 0x29, 0xd4, 0x25, 0x0d, 0x77, 0x38,
+	0xe1, 0x8b, 0xeb, 0x66, 0xcf, 0xf0, 0x1e, 0x42, 0xf6, 0xf7, 0x62, 0xaa, 0x8e, 0xd1, 0xfd, 0xbf,
+	0x79, 0x15, 0xb3, 0xf8, 0xe9, 0xef, 0x00, 0x63, 0x2e, 0xa0, 0xdd, 0x98, 0x02, 0x00, 0x00,
 }
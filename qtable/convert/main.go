@@ -0,0 +1,96 @@
+// convert turns the JSON checkpoints of the qlearning players into the binary
+// Q-table format and back, the direction is decided by the file endings
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/arenaio/woodhack2018/qtable"
+)
+
+// checkpoint is the JSON format of the qlearning players
+type checkpoint struct {
+	Table            map[string]map[int64]float64 `json:"Table"`
+	Games            int64                        `json:"Games,omitempty"`
+	ExplorationRate  float64                      `json:"ExplorationRate"`
+	ExplorationDecay float64                      `json:"ExplorationDecay,omitempty"`
+	LearningRate     float64                      `json:"LearningRate"`
+	DiscountFactor   float64                      `json:"DiscountFactor"`
+}
+
+func main() {
+	in := flag.String("in", "", "file to convert, JSON if it ends in .json")
+	out := flag.String("out", "", "file to write, JSON if it ends in .json")
+	flag.Parse()
+
+	if len(*in) == 0 || len(*out) == 0 {
+		log.Fatal("both -in and -out are required")
+	}
+
+	h, table, err := load(*in)
+	if err != nil {
+		log.Fatalf("unable to load %s: %s", *in, err)
+	}
+
+	if err := store(*out, h, table); err != nil {
+		log.Fatalf("unable to store %s: %s", *out, err)
+	}
+	log.Printf("converted %d states from %s to %s", len(table), *in, *out)
+}
+
+func load(path string) (qtable.Header, qtable.Table, error) {
+	if !strings.HasSuffix(path, ".json") {
+		return qtable.Load(path)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return qtable.Header{}, nil, err
+	}
+
+	var c checkpoint
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return qtable.Header{}, nil, err
+	}
+
+	h := qtable.Header{
+		Games:            c.Games,
+		ExplorationRate:  c.ExplorationRate,
+		ExplorationDecay: c.ExplorationDecay,
+		LearningRate:     c.LearningRate,
+		DiscountFactor:   c.DiscountFactor,
+	}
+	for state := range c.Table {
+		h.Fields = qtable.Fields(state)
+		break
+	}
+	if h.Fields == 0 {
+		return qtable.Header{}, nil, errors.New("empty table")
+	}
+
+	return h, c.Table, nil
+}
+
+func store(path string, h qtable.Header, table qtable.Table) error {
+	if !strings.HasSuffix(path, ".json") {
+		return qtable.Save(path, h, table)
+	}
+
+	raw, err := json.Marshal(checkpoint{
+		Table:            table,
+		Games:            h.Games,
+		ExplorationRate:  h.ExplorationRate,
+		ExplorationDecay: h.ExplorationDecay,
+		LearningRate:     h.LearningRate,
+		DiscountFactor:   h.DiscountFactor,
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
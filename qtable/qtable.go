@@ -0,0 +1,323 @@
+// Package qtable stores Q-tables of the qlearning players in a compact binary
+// format.
+//
+// A file starts with a header of the magic "QTBL", the format version, the
+// number of fields, the games played and the learning parameters (version 1
+// files lack the games and the exploration decay). It's followed by one entry
+// per state: the fields packed with 2 bits each (0: empty, 1: me, 2:
+// opponent) and a float32 value for every action, little endian. States are
+// keyed like the players do, by the concatenated field values.
+package qtable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const magic = "QTBL"
+
+// Version of the format written
+const Version uint16 = 2
+
+// MaxMessageSize is the message size limit of the parameter server and its
+// clients, the first pull of an actor sends the whole table
+const MaxMessageSize = 1 << 30
+
+// Table maps the key of a state to the values of its actions
+type Table map[string]map[int64]float64
+
+type Header struct {
+	Fields           int
+	Games            int64 // played to learn the table
+	ExplorationRate  float64
+	ExplorationDecay float64 // 0 if unknown
+	LearningRate     float64
+	DiscountFactor   float64
+}
+
+type Writer struct {
+	w      *bufio.Writer
+	fields int
+	buf    []byte
+}
+
+// NewWriter writes the header, entries are written as they come
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if h.Fields <= 0 {
+		return nil, fmt.Errorf("invalid number of fields %d", h.Fields)
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, err
+	}
+	header := []interface{}{
+		Version,
+		uint32(h.Fields),
+		uint64(h.Games),
+		h.ExplorationRate,
+		h.ExplorationDecay,
+		h.LearningRate,
+		h.DiscountFactor,
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Writer{
+		w:      bw,
+		fields: h.Fields,
+		buf:    make([]byte, entrySize(h.Fields)),
+	}, nil
+}
+
+// entrySize returns the bytes of an entry, the packed state and the values
+func entrySize(fields int) int {
+	return (fields+3)/4 + fields*4
+}
+
+// Write adds the action values of a state, missing actions are stored as 0
+func (w *Writer) Write(state string, actions map[int64]float64) error {
+	keySize := (w.fields + 3) / 4
+	if err := pack(state, w.buf[:keySize], w.fields); err != nil {
+		return err
+	}
+
+	for action := 0; action < w.fields; action++ {
+		value := math.Float32bits(float32(actions[int64(action)]))
+		binary.LittleEndian.PutUint32(w.buf[keySize+action*4:], value)
+	}
+
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// Flush writes buffered entries, it has to be called after the last entry
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+type Reader struct {
+	r      *bufio.Reader
+	fields int
+	buf    []byte
+}
+
+// NewReader reads and checks the header
+func NewReader(r io.Reader) (*Reader, Header, error) {
+	br := bufio.NewReader(r)
+
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, m); err != nil || string(m) != magic {
+		return nil, Header{}, errors.New("not a Q-table file")
+	}
+
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, Header{}, fmt.Errorf("invalid header: %s", err)
+	}
+
+	var fields uint32
+	var games uint64
+	var h Header
+	var header []interface{}
+	switch version {
+	case 1:
+		header = []interface{}{
+			&fields,
+			&h.ExplorationRate,
+			&h.LearningRate,
+			&h.DiscountFactor,
+		}
+	case 2:
+		header = []interface{}{
+			&fields,
+			&games,
+			&h.ExplorationRate,
+			&h.ExplorationDecay,
+			&h.LearningRate,
+			&h.DiscountFactor,
+		}
+	default:
+		return nil, Header{}, fmt.Errorf("unsupported version %d", version)
+	}
+
+	for _, v := range header {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, Header{}, fmt.Errorf("invalid header: %s", err)
+		}
+	}
+	h.Fields = int(fields)
+	h.Games = int64(games)
+
+	return &Reader{
+		r:      br,
+		fields: h.Fields,
+		buf:    make([]byte, entrySize(h.Fields)),
+	}, h, nil
+}
+
+// Read returns the next entry or io.EOF after the last one
+func (r *Reader) Read() (string, map[int64]float64, error) {
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", nil, errors.New("truncated entry")
+		}
+		return "", nil, err
+	}
+
+	keySize := (r.fields + 3) / 4
+	actions := make(map[int64]float64, r.fields)
+	for action := 0; action < r.fields; action++ {
+		value := binary.LittleEndian.Uint32(r.buf[keySize+action*4:])
+		actions[int64(action)] = float64(math.Float32frombits(value))
+	}
+
+	return unpack(r.buf[:keySize], r.fields), actions, nil
+}
+
+// Save writes a whole table to a file
+func Save(path string, h Header, table Table) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w, err := NewWriter(file, h)
+	if err != nil {
+		return err
+	}
+	for state, actions := range table {
+		if err := w.Write(state, actions); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Checkpoint saves a table atomically, the file is written under a temporary
+// name first and replaces the previous checkpoint once complete. Up to keep
+// previous checkpoints are kept as path.1 (the latest) to path.keep.
+func Checkpoint(path string, keep int, h Header, table Table) error {
+	tmp := path + ".tmp"
+	if err := Save(tmp, h, table); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := Rotate(path, keep); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Rotate moves a file to path.1 and the previous ones up to path.keep out of
+// the way, missing files are skipped
+func Rotate(path string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	for i := keep - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Load reads a whole table from a file
+func Load(path string) (Header, Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	defer file.Close()
+
+	r, h, err := NewReader(file)
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	table := make(Table)
+	for {
+		state, actions, err := r.Read()
+		if err == io.EOF {
+			return h, table, nil
+		}
+		if err != nil {
+			return Header{}, nil, err
+		}
+		table[state] = actions
+	}
+}
+
+// Fields returns the number of fields of a state key
+func Fields(state string) int {
+	return len(state) - strings.Count(state, "-")
+}
+
+// pack stores the fields of a state key with 2 bits each
+func pack(state string, key []byte, fields int) error {
+	for i := range key {
+		key[i] = 0
+	}
+
+	field := 0
+	for i := 0; i < len(state); i++ {
+		var v byte
+		switch {
+		case state[i] == '0':
+			v = 0
+		case state[i] == '1':
+			v = 1
+		case state[i] == '-' && i+1 < len(state) && state[i+1] == '1':
+			v = 2
+			i++
+		default:
+			return fmt.Errorf("invalid state %q", state)
+		}
+
+		if field >= fields {
+			return fmt.Errorf("state %q has too many fields", state)
+		}
+		key[field/4] |= v << uint(field%4*2)
+		field++
+	}
+
+	if field != fields {
+		return fmt.Errorf("state %q has too few fields", state)
+	}
+	return nil
+}
+
+func unpack(key []byte, fields int) string {
+	state := make([]string, fields)
+	for field := range state {
+		switch key[field/4] >> uint(field%4*2) & 3 {
+		case 1:
+			state[field] = "1"
+		case 2:
+			state[field] = "-1"
+		default:
+			state[field] = "0"
+		}
+	}
+	return strings.Join(state, "")
+}
@@ -0,0 +1,203 @@
+// server is a parameter server holding a Q-table shared by many qlearning
+// actors. Actors push the targets they learned and pull the entries changed
+// since their last pull, the server checkpoints the shared table regularly and
+// when it is stopped.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/qtable"
+)
+
+func main() {
+	address := flag.String("address", ":8100", "address to serve the table on")
+	file := flag.String("file", "", "binary checkpoint to start from")
+	checkpoint := flag.String("checkpoint", "q-table.qtable", "file to write checkpoints to, the previous ones are kept as file.1, file.2, ...")
+	checkpointEvery := flag.Duration("checkpointEvery", time.Minute, "time between checkpoints, 0 only saves the table when the server is stopped")
+	keep := flag.Int("keep", 3, "number of previous checkpoints to keep")
+	flag.Parse()
+
+	s := NewServer()
+	if len(*file) > 0 {
+		h, table, err := qtable.Load(*file)
+		if err != nil {
+			log.Fatalf("unable to load %s: %s", *file, err)
+		}
+		s.load(h, table)
+		log.Printf("loaded %d states after %d games from %s", len(table), h.Games, *file)
+	}
+
+	lis, err := net.Listen("tcp", *address)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	if *checkpointEvery > 0 {
+		go func() {
+			for range time.Tick(*checkpointEvery) {
+				s.checkpoint(*checkpoint, *keep)
+			}
+		}()
+	}
+
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(qtable.MaxMessageSize), grpc.MaxSendMsgSize(qtable.MaxMessageSize))
+	proto.RegisterQTableServer(srv, s)
+
+	// finish the pending pushes and save them before exiting
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		log.Printf("%s received, stopping", <-signals)
+		srv.GracefulStop()
+	}()
+
+	log.Printf("serving the table on %s", *address)
+	if err := srv.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+	s.checkpoint(*checkpoint, *keep)
+}
+
+type Server struct {
+	m       sync.Mutex
+	table   qtable.Table
+	changed map[string]int64 // version of the last change of a state
+	version int64            // incremented by every push
+	header  qtable.Header    // parameters of the last push
+	saved   int64            // version of the last checkpoint
+	saving  sync.Mutex       // held while a checkpoint is written
+}
+
+func NewServer() *Server {
+	return &Server{
+		table:   make(qtable.Table),
+		changed: make(map[string]int64),
+	}
+}
+
+// load starts from a checkpoint, all states are sent on the first pull
+func (s *Server) load(h qtable.Header, table qtable.Table) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.version++
+	s.table = table
+	s.header = h
+	for state := range table {
+		s.changed[state] = s.version
+	}
+	s.saved = s.version
+}
+
+// Push applies the updates of an actor in order
+func (s *Server) Push(ctx context.Context, u *proto.Updates) (*proto.Version, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// a batch with an invalid update is rejected as a whole
+	for _, update := range u.Updates {
+		if update.Action < 0 || update.Action >= int64(qtable.Fields(update.State)) {
+			return nil, status.Errorf(codes.InvalidArgument, "action %d is not a field of state %q", update.Action, update.State)
+		}
+	}
+
+	s.version++
+	for _, update := range u.Updates {
+		actionTable, found := s.table[update.State]
+		if !found {
+			actionTable = make(map[int64]float64)
+			for i := 0; i < qtable.Fields(update.State); i++ {
+				actionTable[int64(i)] = 0
+			}
+			s.table[update.State] = actionTable
+		}
+
+		actionTable[update.Action] += update.Step * (update.Target - actionTable[update.Action])
+		s.changed[update.State] = s.version
+	}
+
+	s.header.Games += u.Games
+	s.header.ExplorationRate = u.ExplorationRate
+	s.header.ExplorationDecay = u.ExplorationDecay
+	s.header.LearningRate = u.LearningRate
+	s.header.DiscountFactor = u.DiscountFactor
+
+	return &proto.Version{Version: s.version}, nil
+}
+
+// Pull returns the states changed after a version, all of them for version 0
+func (s *Server) Pull(ctx context.Context, v *proto.Version) (*proto.Entries, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries := &proto.Entries{Version: s.version}
+	for state, version := range s.changed {
+		if version <= v.Version {
+			continue
+		}
+
+		actionTable := s.table[state]
+		values := make([]float64, qtable.Fields(state))
+		for action, value := range actionTable {
+			if action >= 0 && action < int64(len(values)) {
+				values[action] = value
+			}
+		}
+		entries.Entries = append(entries.Entries, &proto.Entry{State: state, Values: values})
+	}
+
+	return entries, nil
+}
+
+// checkpoint saves a snapshot of the table taken between two pushes, the
+// file is written without holding the lock
+func (s *Server) checkpoint(path string, keep int) {
+	s.saving.Lock()
+	defer s.saving.Unlock()
+
+	s.m.Lock()
+	if s.version == s.saved || len(s.table) == 0 {
+		s.m.Unlock()
+		return
+	}
+
+	table := make(qtable.Table, len(s.table))
+	for state, actionTable := range s.table {
+		values := make(map[int64]float64, len(actionTable))
+		for action, value := range actionTable {
+			values[action] = value
+		}
+		table[state] = values
+	}
+	h := s.header
+	for state := range table {
+		h.Fields = qtable.Fields(state)
+		break
+	}
+	version := s.version
+	s.m.Unlock()
+
+	if err := qtable.Checkpoint(path, keep, h, table); err != nil {
+		log.Printf("unable to checkpoint version %d: %s", version, err)
+		return
+	}
+
+	s.m.Lock()
+	s.saved = version
+	s.m.Unlock()
+	log.Printf("%s saved with %d states after %d games", path, len(table), h.Games)
+}
@@ -0,0 +1,74 @@
+// Package record reads and writes finished games as JSON lines.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/arenaio/woodhack2018/proto"
+)
+
+type Record struct {
+	GameType int64        `json:"gameType"`
+	Players  [2]string    `json:"players"` // in seat order
+	Rules    *proto.Rules `json:"rules,omitempty"`
+	Opening  []int64      `json:"opening,omitempty"` // moves placed before the game started
+	Moves    []int64      `json:"moves"`
+	Swapped  bool         `json:"swapped,omitempty"` // the second player took over the first move
+	Result   int64        `json:"result"`            // for the first seat
+}
+
+type Writer struct {
+	m       sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// Create opens a record file for appending
+func Create(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (w *Writer) Write(r Record) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	return w.encoder.Encode(r)
+}
+
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+// Read calls fn for every record of a file until fn returns an error
+func Read(path string, fn func(Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
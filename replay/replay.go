@@ -0,0 +1,235 @@
+// Package replay keeps transitions of past games to learn from them again,
+// sampled uniformly or by priority (prioritized experience replay).
+package replay
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/arenaio/woodhack2018/proto"
+)
+
+// Transition is a move of a player and what followed until it is the
+// player's turn again or the game is over, the states are from the view of
+// the player (1 own, -1 opponent fields)
+type Transition struct {
+	State       []int64
+	Action      int64
+	Reward      int64
+	FutureState []int64
+	FutureMoves []int64 // legal moves in the future state, none if unknown
+	Terminal    bool    // won, lost or draw, there is no future to bootstrap from
+}
+
+// minPriority keeps transitions without an error sampleable
+const minPriority = 1e-6
+
+// Buffer is a bounded ring buffer of transitions, the oldest one is replaced
+// once it is full.
+//
+// With a priority exponent above 0 transitions are sampled proportionally to
+// their priority (the size of their last TD error) to the power of it, new
+// ones get the highest priority seen so far. The priorities are kept in a sum
+// tree: the leaves are at capacity + index and every node is the sum of its
+// children, so the root is the total.
+type Buffer struct {
+	transitions []Transition
+	capacity    int
+	next        int
+
+	alpha       float64
+	tree        []float64 // nil for uniform sampling
+	maxPriority float64
+}
+
+// New creates a buffer, alpha 0 samples uniformly
+func New(capacity int, alpha float64) *Buffer {
+	b := &Buffer{
+		transitions: make([]Transition, 0, capacity),
+		capacity:    capacity,
+		alpha:       alpha,
+		maxPriority: 1,
+	}
+	if alpha > 0 {
+		b.tree = make([]float64, 2*capacity)
+	}
+	return b
+}
+
+func (b *Buffer) Len() int {
+	return len(b.transitions)
+}
+
+// Get returns a transition by the index returned by Sample
+func (b *Buffer) Get(i int) Transition {
+	return b.transitions[i]
+}
+
+// Add stores a transition, replacing the oldest one if the buffer is full
+func (b *Buffer) Add(t Transition) {
+	i := b.next
+	if len(b.transitions) < b.capacity {
+		b.transitions = append(b.transitions, t)
+	} else {
+		b.transitions[i] = t
+	}
+	b.next = (b.next + 1) % b.capacity
+
+	if b.tree != nil {
+		b.set(i, b.maxPriority)
+	}
+}
+
+// Sample draws n transitions with replacement and returns their indices and
+// importance sampling weights, which correct the bias of prioritized sampling
+// by beta (1 fully) and are scaled to at most 1. The weights are all 1 for
+// uniform sampling.
+func (b *Buffer) Sample(r *rand.Rand, n int, beta float64) ([]int, []float64) {
+	if len(b.transitions) == 0 {
+		return nil, nil
+	}
+	indices := make([]int, n)
+	weights := make([]float64, n)
+
+	if b.tree == nil {
+		for k := range indices {
+			indices[k] = r.Intn(len(b.transitions))
+			weights[k] = 1
+		}
+		return indices, weights
+	}
+
+	total := b.tree[1]
+	max := 0.0
+	for k := range indices {
+		indices[k] = b.find(r.Float64() * total)
+
+		p := b.tree[b.capacity+indices[k]] / total
+		weights[k] = math.Pow(float64(len(b.transitions))*p, -beta)
+		if weights[k] > max {
+			max = weights[k]
+		}
+	}
+	for k := range weights {
+		weights[k] /= max
+	}
+	return indices, weights
+}
+
+// Update sets the priority of a transition by its latest TD error
+func (b *Buffer) Update(i int, tdError float64) {
+	if b.tree == nil {
+		return
+	}
+
+	priority := math.Pow(math.Abs(tdError)+minPriority, b.alpha)
+	if priority > b.maxPriority {
+		b.maxPriority = priority
+	}
+	b.set(i, priority)
+}
+
+func (b *Buffer) set(i int, priority float64) {
+	node := b.capacity + i
+	b.tree[node] = priority
+	for node /= 2; node > 0; node /= 2 {
+		b.tree[node] = b.tree[2*node] + b.tree[2*node+1]
+	}
+}
+
+// find returns the index of the transition at a prefix sum of the priorities
+func (b *Buffer) find(x float64) int {
+	node := 1
+	for node < b.capacity {
+		left := 2 * node
+		if x < b.tree[left] || b.tree[left+1] == 0 {
+			node = left
+		} else {
+			x -= b.tree[left]
+			node = left + 1
+		}
+	}
+
+	i := node - b.capacity
+	if i >= len(b.transitions) {
+		// rounding ended up in an empty leaf
+		i = len(b.transitions) - 1
+	}
+	return i
+}
+
+// Ply is a position of a recorded game from the view of the player to move,
+// its legal moves and the move made
+type Ply struct {
+	State      []int64
+	LegalMoves []int64
+	Move       int64
+}
+
+// Episodes turns the plies of a game made alternately by both seats into the
+// transitions of both players. firstSeat made the first ply and the result is
+// the one of the first seat as in the records.
+func Episodes(plies []Ply, firstSeat, result int64) []Transition {
+	if len(plies) == 0 {
+		return nil
+	}
+
+	// the final position from the view of the player who made the last move
+	last := plies[len(plies)-1]
+	final := make([]int64, len(last.State))
+	copy(final, last.State)
+	final[last.Move] = 1
+
+	transitions := make([]Transition, len(plies))
+	for i, ply := range plies {
+		t := Transition{
+			State:  ply.State,
+			Action: ply.Move,
+			Reward: proto.ValidMove,
+		}
+
+		if i+2 < len(plies) {
+			t.FutureState = plies[i+2].State
+			t.FutureMoves = plies[i+2].LegalMoves
+		} else {
+			t.Terminal = true
+			t.FutureState = final
+			if i != len(plies)-1 {
+				t.FutureState = opposite(final)
+			}
+
+			seat := firstSeat
+			if i%2 == 1 {
+				seat = 3 - firstSeat
+			}
+			t.Reward = resultOf(seat, result)
+		}
+
+		transitions[i] = t
+	}
+	return transitions
+}
+
+// opposite returns a state from the view of the other player
+func opposite(state []int64) []int64 {
+	out := make([]int64, len(state))
+	for i, v := range state {
+		out[i] = -v
+	}
+	return out
+}
+
+// resultOf returns the result of a seat from the one of the first seat
+func resultOf(seat, result int64) int64 {
+	if seat == 1 {
+		return result
+	}
+
+	switch result {
+	case proto.Won:
+		return proto.Lost
+	case proto.Lost:
+		return proto.Won
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+// Package seats decides which of two paired players moves first.
+package seats
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// Join gives the first seat to the player who joined first
+	Join = "join"
+	// Random picks the first player at random
+	Random = "random"
+	// Alternate swaps the seats of two players each time they meet
+	Alternate = "alternate"
+	// Balanced gives the first seat to the player of a pair who had it less
+	// often, ties are decided at random
+	Balanced = "balanced"
+)
+
+type pair struct {
+	a, b string
+}
+
+func newPair(name1, name2 string) pair {
+	if name1 > name2 {
+		name1, name2 = name2, name1
+	}
+	return pair{name1, name2}
+}
+
+type Assigner struct {
+	policy string
+	m      sync.Mutex
+	r      *rand.Rand
+	last   map[pair]string         // who had the first seat in the last game
+	firsts map[pair]map[string]int // how often a player had the first seat
+}
+
+func NewAssigner(policy string) (*Assigner, error) {
+	switch policy {
+	case Join, Random, Alternate, Balanced:
+	default:
+		return nil, fmt.Errorf("unknown seat policy %q", policy)
+	}
+
+	return &Assigner{
+		policy: policy,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		last:   make(map[pair]string),
+		firsts: make(map[pair]map[string]int),
+	}, nil
+}
+
+// Assign reports whether the player who joined first gets the first seat
+func (a *Assigner) Assign(joinedFirst, joinedSecond string) bool {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	first := a.choose(joinedFirst, joinedSecond)
+
+	p := newPair(joinedFirst, joinedSecond)
+	a.last[p] = first
+	if _, found := a.firsts[p]; !found {
+		a.firsts[p] = make(map[string]int)
+	}
+	a.firsts[p][first]++
+
+	return first == joinedFirst
+}
+
+func (a *Assigner) choose(joinedFirst, joinedSecond string) string {
+	if joinedFirst == joinedSecond {
+		// both seats are the same for a bot playing against itself
+		return joinedFirst
+	}
+
+	p := newPair(joinedFirst, joinedSecond)
+	switch a.policy {
+	case Alternate:
+		if last, found := a.last[p]; found {
+			if last == joinedFirst {
+				return joinedSecond
+			}
+			return joinedFirst
+		}
+	case Balanced:
+		counts := a.firsts[p]
+		if counts[joinedFirst] < counts[joinedSecond] {
+			return joinedFirst
+		}
+		if counts[joinedSecond] < counts[joinedFirst] {
+			return joinedSecond
+		}
+	case Join:
+		return joinedFirst
+	}
+
+	if a.r.Intn(2) == 0 {
+		return joinedFirst
+	}
+	return joinedSecond
+}
+
+type rematch struct {
+	opening []int64
+	first   string
+}
+
+// Rematches remembers the openings of a pair, so each can be played a second
+// time with reversed seats. A pair playing several games at once has a queue
+// of them, oldest first.
+type Rematches struct {
+	m       sync.Mutex
+	pending map[pair][]rematch
+}
+
+func NewRematches() *Rematches {
+	return &Rematches{
+		pending: make(map[pair][]rematch),
+	}
+}
+
+// Add remembers an opening played by two players in seat order
+func (r *Rematches) Add(first, second string, opening []int64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	p := newPair(first, second)
+	r.pending[p] = append(r.pending[p], rematch{opening, first})
+}
+
+// Take returns and forgets the oldest pending opening of two players together
+// with the player who gets the first seat this time
+func (r *Rematches) Take(name1, name2 string) (opening []int64, first string, found bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	p := newPair(name1, name2)
+	queue := r.pending[p]
+	if len(queue) == 0 {
+		return nil, "", false
+	}
+	m := queue[0]
+	if len(queue) == 1 {
+		delete(r.pending, p)
+	} else {
+		r.pending[p] = queue[1:]
+	}
+
+	first = name1
+	if m.first == name1 {
+		first = name2
+	}
+	return m.opening, first, true
+}
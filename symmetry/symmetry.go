@@ -0,0 +1,88 @@
+// Package symmetry maps tic-tac-toe positions that are rotations or
+// reflections of each other to one canonical position.
+package symmetry
+
+// Square returns the 8 rotations and reflections of a 3x3 board as
+// permutations, transform[field] is the field it's moved to
+func Square() [][]int64 {
+	var transforms [][]int64
+	for rotations := 0; rotations < 4; rotations++ {
+		for _, mirror := range []bool{false, true} {
+			transform := make([]int64, 9)
+			for field := int64(0); field < 9; field++ {
+				row, column := field/3, field%3
+				if mirror {
+					column = 2 - column
+				}
+				for i := 0; i < rotations; i++ {
+					row, column = column, 2-row
+				}
+				transform[field] = row*3 + column
+			}
+			transforms = append(transforms, transform)
+		}
+	}
+	return transforms
+}
+
+// Nested returns the symmetries of a board nested depth levels like in
+// ultimate tic-tac-toe (depth 2), every level is transformed the same way so
+// the sub board a move sends the opponent to is transformed along
+func Nested(depth int) [][]int64 {
+	size := int64(1)
+	for i := 0; i < depth; i++ {
+		size *= 9
+	}
+
+	var transforms [][]int64
+	for _, square := range Square() {
+		transform := make([]int64, size)
+		for field := int64(0); field < size; field++ {
+			moved, scale := int64(0), int64(1)
+			for rest := field; scale < size; rest, scale = rest/9, scale*9 {
+				moved += square[rest%9] * scale
+			}
+			transform[field] = moved
+		}
+		transforms = append(transforms, transform)
+	}
+	return transforms
+}
+
+// Canonical returns the smallest of the transformed states and the
+// transformation leading to it, the field i of state is field transform[i] of
+// the canonical state
+func Canonical(state []int64, transforms [][]int64) (canonical, transform []int64) {
+	for _, t := range transforms {
+		transformed := make([]int64, len(state))
+		for i, v := range state {
+			transformed[t[i]] = v
+		}
+
+		if canonical == nil || less(transformed, canonical) {
+			canonical, transform = transformed, t
+		}
+	}
+
+	if canonical == nil {
+		return state, nil
+	}
+	return canonical, transform
+}
+
+// Apply returns the field of the canonical state a field is moved to
+func Apply(transform []int64, field int64) int64 {
+	if transform == nil || field < 0 || field >= int64(len(transform)) {
+		return field
+	}
+	return transform[field]
+}
+
+func less(a, b []int64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
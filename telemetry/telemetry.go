@@ -0,0 +1,188 @@
+// Package telemetry records learning curves of the training players as CSV or
+// JSON lines, one row per interval, to plot and compare training runs.
+//
+// Results, invalid moves and episode lengths are rates and averages over a
+// rolling window of the last episodes, the TD errors are the ones of the
+// updates since the previous row.
+package telemetry
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arenaio/woodhack2018/proto"
+)
+
+// Row of the learning curve
+type Row struct {
+	Episode         int64   `json:"episode"`
+	Seconds         float64 `json:"seconds"` // since the recorder was created
+	Episodes        int     `json:"episodes"`
+	Won             float64 `json:"won"`
+	Draw            float64 `json:"draw"`
+	Lost            float64 `json:"lost"`
+	InvalidRate     float64 `json:"invalidRate"`   // of all moves sent
+	EpisodeLength   float64 `json:"episodeLength"` // in valid moves of the player
+	TableSize       int     `json:"tableSize"`     // states of a table or weights of an approximation
+	ExplorationRate float64 `json:"explorationRate"`
+	Updates         int64   `json:"updates"`
+	TDErrorMean     float64 `json:"tdErrorMean"` // of the absolute errors
+	TDErrorRMS      float64 `json:"tdErrorRMS"`
+	TDErrorMax      float64 `json:"tdErrorMax"`
+}
+
+var header = []string{
+	"episode", "seconds", "episodes", "won", "draw", "lost", "invalidRate", "episodeLength",
+	"tableSize", "explorationRate", "updates", "tdErrorMean", "tdErrorRMS", "tdErrorMax",
+}
+
+func (r Row) fields() []string {
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	}
+	return []string{
+		strconv.FormatInt(r.Episode, 10), f(r.Seconds), strconv.Itoa(r.Episodes),
+		f(r.Won), f(r.Draw), f(r.Lost), f(r.InvalidRate), f(r.EpisodeLength),
+		strconv.Itoa(r.TableSize), f(r.ExplorationRate), strconv.FormatInt(r.Updates, 10),
+		f(r.TDErrorMean), f(r.TDErrorRMS), f(r.TDErrorMax),
+	}
+}
+
+// episode is what the window keeps of a finished episode
+type episode struct {
+	result         int64
+	moves, invalid int64
+}
+
+type Recorder struct {
+	file    *os.File
+	csv     *csv.Writer   // nil for JSON lines
+	encoder *json.Encoder // nil for CSV
+	start   time.Time
+
+	window   []episode
+	next     int
+	updates  int64
+	absSum   float64
+	squares  float64
+	maxError float64
+}
+
+// New appends to a telemetry file, CSV if the path ends in .csv and JSON
+// lines otherwise, the CSV header is written to new files. The window is the
+// number of episodes the rates are taken over, at least one.
+func New(path string, window int) (*Recorder, error) {
+	if window < 1 {
+		window = 1
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		file:   file,
+		start:  time.Now(),
+		window: make([]episode, 0, window),
+	}
+
+	if !strings.HasSuffix(path, ".csv") {
+		r.encoder = json.NewEncoder(file)
+		return r, nil
+	}
+
+	r.csv = csv.NewWriter(file)
+	info, err := file.Stat()
+	if err == nil && info.Size() == 0 {
+		r.csv.Write(header)
+		r.csv.Flush()
+		err = r.csv.Error()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// TDError counts the error of an update
+func (r *Recorder) TDError(delta float64) {
+	r.updates++
+	r.absSum += math.Abs(delta)
+	r.squares += delta * delta
+	r.maxError = math.Max(r.maxError, math.Abs(delta))
+}
+
+// Episode counts a finished episode with its result and the valid and
+// invalid moves sent
+func (r *Recorder) Episode(result, moves, invalid int64) {
+	e := episode{result, moves, invalid}
+	if len(r.window) < cap(r.window) {
+		r.window = append(r.window, e)
+	} else {
+		r.window[r.next] = e
+		r.next = (r.next + 1) % len(r.window)
+	}
+}
+
+// Write adds a row and starts a new interval of TD errors
+func (r *Recorder) Write(episodes int64, explorationRate float64, tableSize int) error {
+	row := Row{
+		Episode:         episodes,
+		Seconds:         time.Since(r.start).Seconds(),
+		Episodes:        len(r.window),
+		TableSize:       tableSize,
+		ExplorationRate: explorationRate,
+		Updates:         r.updates,
+		TDErrorMax:      r.maxError,
+	}
+
+	var moves, invalid int64
+	for _, e := range r.window {
+		switch e.result {
+		case proto.Won:
+			row.Won++
+		case proto.Draw:
+			row.Draw++
+		case proto.Lost:
+			row.Lost++
+		}
+		moves += e.moves
+		invalid += e.invalid
+	}
+	if n := float64(len(r.window)); n > 0 {
+		row.Won /= n
+		row.Draw /= n
+		row.Lost /= n
+		row.EpisodeLength = float64(moves) / n
+	}
+	if moves+invalid > 0 {
+		row.InvalidRate = float64(invalid) / float64(moves+invalid)
+	}
+	if r.updates > 0 {
+		row.TDErrorMean = r.absSum / float64(r.updates)
+		row.TDErrorRMS = math.Sqrt(r.squares / float64(r.updates))
+	}
+
+	r.updates = 0
+	r.absSum = 0
+	r.squares = 0
+	r.maxError = 0
+
+	if r.encoder != nil {
+		return r.encoder.Encode(row)
+	}
+	r.csv.Write(row.fields())
+	r.csv.Flush()
+	return r.csv.Error()
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
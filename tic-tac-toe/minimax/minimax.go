@@ -0,0 +1,141 @@
+// Package minimax plays regular tic-tac-toe perfectly using an alpha-beta
+// search of the whole game tree.
+package minimax
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var lines = [][]int64{
+	{0, 1, 2},
+	{3, 4, 5},
+	{6, 7, 8},
+	{0, 4, 8},
+	{6, 4, 2},
+	{0, 3, 6},
+	{1, 4, 7},
+	{2, 5, 8},
+}
+
+// win is the value of a won game, faster wins are worth more
+const win = 10
+
+type board [9]int64
+
+// Player picks moves for states from the view of the player to move, 1 are
+// its own fields and -1 the ones of the opponent
+type Player struct {
+	m sync.Mutex
+	r *rand.Rand // picks among equally good moves if set
+}
+
+// New creates a player, a random one picks any of the best moves, otherwise
+// the first one is played
+func New(random bool) *Player {
+	p := &Player{}
+	if random {
+		p.r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return p
+}
+
+// Move returns a best move or -1 if the game is already over
+func (p *Player) Move(state []int64) int64 {
+	moves := p.BestMoves(state)
+	if len(moves) == 0 {
+		return -1
+	}
+	if p.r == nil {
+		return moves[0]
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	return moves[p.r.Intn(len(moves))]
+}
+
+// BestMoves returns all moves leading to the best result
+func (p *Player) BestMoves(state []int64) []int64 {
+	var b board
+	copy(b[:], state)
+	if b.hasLine(1) || b.hasLine(-1) {
+		return nil
+	}
+
+	var moves []int64
+	best := int64(-win - 1)
+	for move := int64(0); move < 9; move++ {
+		if b[move] != 0 {
+			continue
+		}
+
+		value := -negamax(b.play(move), 1, -win-1, win+1)
+		switch {
+		case value > best:
+			best = value
+			moves = []int64{move}
+		case value == best:
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// Value returns the result of a state with perfect play, positive values are
+// wins for the player to move and larger the faster they are
+func Value(state []int64) int64 {
+	var b board
+	copy(b[:], state)
+	return negamax(b, 0, -win-1, win+1)
+}
+
+// negamax returns the value of a board for the player to move, which is
+// always 1, within the window of alpha and beta
+func negamax(b board, plies, alpha, beta int64) int64 {
+	if b.hasLine(-1) {
+		return -(win - plies)
+	}
+
+	best := int64(-win - 1)
+	for move := int64(0); move < 9; move++ {
+		if b[move] != 0 {
+			continue
+		}
+
+		value := -negamax(b.play(move), plies+1, -beta, -alpha)
+		if value > best {
+			best = value
+		}
+		if value > alpha {
+			alpha = value
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+
+	if best == -win-1 {
+		return 0 // draw, the board is full
+	}
+	return best
+}
+
+// play returns the board after a move from the view of the opponent
+func (b board) play(move int64) board {
+	b[move] = 1
+	for i := range b {
+		b[i] = -b[i]
+	}
+	return b
+}
+
+func (b board) hasLine(p int64) bool {
+	for _, line := range lines {
+		if b[line[0]] == p && b[line[1]] == p && b[line[2]] == p {
+			return true
+		}
+	}
+	return false
+}
@@ -6,10 +6,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/nsf/termbox-go"
-	"google.golang.org/grpc"
 
+	"github.com/arenaio/woodhack2018/client"
 	"github.com/arenaio/woodhack2018/proto"
 )
 
@@ -18,41 +19,61 @@ func main() {
 	address := flag.String("address", ":8000", "server address")
 	player1Char := flag.String("player1Char", "X", "Character to use for Player 1")
 	player2Char := flag.String("player2Char", "O", "Character to use for Player 2")
+	gameType := flag.Int64("gameType", proto.RegularTicTacToe, "game type to play (regular, misère or qubic)")
 	flag.Parse()
 
+	switch *gameType {
+	case proto.RegularTicTacToe, proto.MisereTicTacToe, proto.Qubic:
+	default:
+		log.Fatalf("game type %d is not supported by the cli", *gameType)
+	}
+
 	err := termbox.Init()
 	if err != nil {
 		log.Fatalf("unable to initialize terminal interface: %s", err)
 	}
 
-	conn, err := grpc.Dial(*address, grpc.WithInsecure())
+	runner, err := client.NewRunner(*address, *name, *gameType)
 	if err != nil {
-		log.Fatalf("unable to connect on port %s: %v", *address, err)
+		log.Fatal(err)
 	}
-	defer conn.Close()
+	defer runner.Close()
 
-	g := NewGame(*name, *player1Char, *player2Char)
-	err = g.run(proto.NewTicTacToeClient(conn), context.Background())
-	if err != nil {
+	g := NewGame(*player1Char, *player2Char, *gameType)
+	_, err = runner.Play(context.Background(), g)
+	if err != nil && err != errQuit {
 		log.Fatal(err)
 	}
 }
 
+// errQuit ends the game when the player quits
+var errQuit = errors.New("quit")
+
 type Game struct {
-	name         string
 	player1Char  string
 	player2Char  string
+	gameType     int64
+	rows         int
+	columns      int
 	positionXOld int
 	positionYOld int
 	positionX    int
 	positionY    int
 }
 
-func NewGame(name, player1Char, player2Char string) *Game {
+func NewGame(player1Char, player2Char string, gameType int64) *Game {
+	rows, columns := 3, 3
+	if gameType == proto.Qubic {
+		// the four layers are drawn next to each other
+		rows, columns = 4, 16
+	}
+
 	return &Game{
-		name:         name,
 		player1Char:  player1Char,
 		player2Char:  player2Char,
+		gameType:     gameType,
+		rows:         rows,
+		columns:      columns,
 		positionX:    1,
 		positionXOld: 1,
 		positionY:    1,
@@ -73,14 +94,34 @@ func (g *Game) parseField(field int64) string {
 	}
 }
 
+func (g *Game) displayPos(x, y int) (pX, pY int) {
+	pX = x * 2
+	pY = y*4 + (y-1)/4*4 // skip the gap between the layers
+	return pX, pY
+}
+
 func (g *Game) goTo(x int, y int) {
-	fmt.Printf("\033[%v;%vH \033[%v;%vH ", g.positionXOld*2, g.positionYOld*4-2, g.positionXOld*2, g.positionYOld*4)
+	pX, pY := g.displayPos(g.positionXOld, g.positionYOld)
+	fmt.Printf("\033[%v;%vH \033[%v;%vH ", pX, pY-2, pX, pY)
 	g.positionXOld = g.positionX
 	g.positionYOld = g.positionY
-	fmt.Printf("\033[0;31m\033[%v;%vH[\033[%v;%vH]\033[0m", x*2, y*4-2, x*2, y*4)
+	pX, pY = g.displayPos(x, y)
+	fmt.Printf("\033[0;31m\033[%v;%vH[\033[%v;%vH]\033[0m", pX, pY-2, pX, pY)
+}
+
+func (g *Game) statusLine() int {
+	if g.gameType == proto.Qubic {
+		return 12
+	}
+	return 8
 }
 
 func (g *Game) drawState(state []int64) {
+	if g.gameType == proto.Qubic {
+		g.drawLayers(state)
+		return
+	}
+
 	fmt.Printf("┌───┬───┬───┐\n")
 	fmt.Printf("│ %s │ %s │ %s │\n", g.parseField(state[0]), g.parseField(state[1]), g.parseField(state[2]))
 	fmt.Printf("├───┼───┼───┤\n")
@@ -90,11 +131,46 @@ func (g *Game) drawState(state []int64) {
 	fmt.Printf("└───┴───┴───┘")
 }
 
+// drawLayers draws the four 4x4 layers of a qubic board next to each other
+func (g *Game) drawLayers(state []int64) {
+	border := func(left, middle, right string) string {
+		return left + strings.Repeat("───"+middle, 3) + "───" + right
+	}
+	top := border("┌", "┬", "┐")
+	separator := border("├", "┼", "┤")
+	bottom := border("└", "┴", "┘")
+
+	for row := 0; row < 4; row++ {
+		if row == 0 {
+			fmt.Printf("%s   %s   %s   %s\n", top, top, top, top)
+		} else {
+			fmt.Printf("%s   %s   %s   %s\n", separator, separator, separator, separator)
+		}
+
+		layers := make([]string, 4)
+		for layer := 0; layer < 4; layer++ {
+			i := layer*16 + row*4
+			layers[layer] = fmt.Sprintf("│ %s │ %s │ %s │ %s │", g.parseField(state[i]), g.parseField(state[i+1]), g.parseField(state[i+2]), g.parseField(state[i+3]))
+		}
+		fmt.Printf("%s\n", strings.Join(layers, "   "))
+	}
+	fmt.Printf("%s   %s   %s   %s\n", bottom, bottom, bottom, bottom)
+	fmt.Printf("     Layer 1             Layer 2             Layer 3             Layer 4")
+}
+
+func (g *Game) getMove(x, y int) int {
+	if g.gameType == proto.Qubic {
+		layer, column := (y-1)/4, (y-1)%4
+		return layer*16 + (x-1)*4 + column
+	}
+	return (x-1)*3 + y - 1
+}
+
 func (g *Game) drawInput(state []int64) {
 	fmt.Printf("\033[0;0H") // go to pos 0/0
 	g.drawState(state)
 	g.goTo(g.positionX, g.positionY) // draw input brackets
-	fmt.Printf("\033[8;0H => Your turn           ")
+	fmt.Printf("\033[%d;0H => Your turn           ", g.statusLine())
 }
 
 func (g *Game) drawFinal(state []int64, message string) {
@@ -102,29 +178,28 @@ func (g *Game) drawFinal(state []int64, message string) {
 	fmt.Println("  ", message)
 }
 
-func (g *Game) run(client proto.TicTacToeClient, ctx context.Context) error {
-	stateResult, err := client.NewGame(ctx, &proto.New{GameType: proto.RegularTicTacToe, Name: g.name})
-	if err != nil {
-		return errors.New(fmt.Sprintf("unable join game: %s", err))
+// Move lets the player choose a field with the arrow keys and space, escape
+// quits the game
+func (g *Game) Move(t *client.Turn) (int64, error) {
+	g.drawInput(t.State)
+	if t.Result == proto.InvalidMove {
+		fmt.Printf("\033[%d;0H => Invalid Move, Your turn           ", g.statusLine())
 	}
 
-	id := stateResult.Id
-	g.drawInput(stateResult.State)
-
 	for {
 		switch ev := termbox.PollEvent(); ev.Type {
 		case termbox.EventKey:
 			switch ev.Key {
 			case termbox.KeyEsc:
 				termbox.Close()
-				return nil
+				return 0, errQuit
 			case termbox.KeyArrowUp:
 				if g.positionX > 1 {
 					g.positionX--
 					g.goTo(g.positionX, g.positionY)
 				}
 			case termbox.KeyArrowDown:
-				if g.positionX < 3 {
+				if g.positionX < g.rows {
 					g.positionX++
 					g.goTo(g.positionX, g.positionY)
 				}
@@ -134,42 +209,36 @@ func (g *Game) run(client proto.TicTacToeClient, ctx context.Context) error {
 					g.goTo(g.positionX, g.positionY)
 				}
 			case termbox.KeyArrowRight:
-				if g.positionY < 3 {
+				if g.positionY < g.columns {
 					g.positionY++
 					g.goTo(g.positionX, g.positionY)
 				}
 			case termbox.KeySpace:
 				// undraw input brackets and place X in orange
-				fmt.Printf("\033[0;94m\033[%v;%vH %s \033[0m", g.positionX*2, g.positionY*4-2, g.player1Char)
-				fmt.Printf("\033[8;0H => Enemies turn           ")
-				moveTarget := (g.positionX-1)*3 + g.positionY - 1
-				stateResult, err = client.Move(ctx, &proto.Action{Id: id, Move: int64(moveTarget)})
-				if err != nil {
-					log.Fatalf("an error trying to make a move: %s", err)
-				}
-				switch stateResult.Result {
-				case proto.InvalidMove:
-					g.drawInput(stateResult.State)
-					fmt.Printf("\033[8;0H => Invalid Move, Your turn           ")
-				case proto.Won:
-					termbox.Close()
-					g.drawFinal(stateResult.State, "You Won")
-					return nil
-				case proto.Lost:
-					termbox.Close()
-					g.drawFinal(stateResult.State, "You Lost")
-					return nil
-				case proto.Draw:
-					termbox.Close()
-					g.drawFinal(stateResult.State, "Game Draw")
-					return nil
-				default: // valid move
-					g.drawInput(stateResult.State)
-				}
+				pX, pY := g.displayPos(g.positionX, g.positionY)
+				fmt.Printf("\033[0;94m\033[%v;%vH %s \033[0m", pX, pY-2, g.player1Char)
+				fmt.Printf("\033[%d;0H => Enemies turn           ", g.statusLine())
+				return int64(g.getMove(g.positionX, g.positionY)), nil
 			}
 		case termbox.EventError:
 			termbox.Close()
-			return ev.Err
+			return 0, ev.Err
 		}
 	}
-}
\ No newline at end of file
+}
+
+// Observe shows the end of the game, the other turns are drawn when the
+// player is asked for the next move
+func (g *Game) Observe(move int64, t *client.Turn) {
+	switch t.Result {
+	case proto.Won:
+		termbox.Close()
+		g.drawFinal(t.State, "You Won")
+	case proto.Lost:
+		termbox.Close()
+		g.drawFinal(t.State, "You Lost")
+	case proto.Draw:
+		termbox.Close()
+		g.drawFinal(t.State, "Game Draw")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"golang.org/x/net/context"
+
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/tic-tac-toe/minimax"
+)
+
+func main() {
+	address := flag.String("address", ":8000", "server address")
+	name := flag.String("name", "Minimax", "bot name")
+	opponent := flag.String("opponent", "", "only play against the bot with this name")
+	random := flag.Bool("random", true, "pick any of the equally good moves instead of the first one")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
+	flag.Parse()
+
+	runner, err := client.NewRunner(*address, *name, proto.RegularTicTacToe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer runner.Close()
+	runner.LogGames = *logGames
+	runner.Opponent = *opponent
+	runner.LogEvery = 1000
+
+	a := agent{minimax.New(*random)}
+	newAgent := func() client.Agent { return a }
+	if err := runner.RunConcurrent(context.Background(), newAgent, 0, *concurrency); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// agent plays the moves of the minimax player, which never makes invalid ones
+// and is shared by games played concurrently
+type agent struct {
+	player *minimax.Player
+}
+
+func (a agent) Move(t *client.Turn) (int64, error) {
+	if t.Result == proto.InvalidMove {
+		return 0, fmt.Errorf("made an invalid move in state %v", t.State)
+	}
+	return a.player.Move(t.State), nil
+}
+
+func (a agent) Observe(move int64, t *client.Turn) {}
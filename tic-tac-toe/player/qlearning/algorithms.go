@@ -0,0 +1,166 @@
+package main
+
+import (
+	"math"
+
+	"github.com/arenaio/woodhack2018/replay"
+	"github.com/arenaio/woodhack2018/symmetry"
+)
+
+// learning algorithms selectable by the -algorithm flag
+const (
+	qLearning     = "q"
+	sarsa         = "sarsa"
+	expectedSarsa = "expected-sarsa"
+	doubleQ       = "double-q"
+	tdLambda      = "td-lambda" // SARSA(λ) with replacing eligibility traces
+)
+
+// minTrace is the eligibility below which a trace is dropped
+const minTrace = 0.001
+
+// trace is the eligibility of an action of a canonical state for TD(λ)
+type trace struct {
+	state  string
+	action int64
+}
+
+// onPolicy tells if the algorithm learns from the next action taken, the
+// update is delayed until it is chosen then
+func (q *qlearning) onPolicy() bool {
+	return q.algorithm == sarsa || q.algorithm == tdLambda
+}
+
+// learn updates the value of the action of a transition with the selected
+// algorithm and returns the TD error, the next action is only used by the
+// on-policy ones. The weight scales the learning rate for replayed
+// transitions.
+func (q *qlearning) learn(t replay.Transition, nextAction int64, weight float64) float64 {
+	if q.algorithm == doubleQ {
+		return q.learnDouble(t, weight)
+	}
+
+	target := float64(t.Reward)
+	if !t.Terminal {
+		target += q.DiscountFactor * q.future(t, nextAction)
+	}
+
+	if q.algorithm == tdLambda {
+		return q.learnTraces(t, target)
+	}
+	return q.update(q.Table, t.State, t.Action, target, weight)
+}
+
+// future estimates the value of the state after a transition
+func (q *qlearning) future(t replay.Transition, nextAction int64) float64 {
+	switch q.algorithm {
+	case sarsa, tdLambda:
+		actionTable, transform := q.getActionTable(q.Table, t.FutureState)
+		return actionTable[symmetry.Apply(transform, nextAction)]
+	case expectedSarsa:
+		// expectation under the exploration strategy
+		moves := movesOf(t.FutureState, t.FutureMoves)
+		actionTable, transform := q.getActionTable(q.Table, t.FutureState)
+		value := func(move int64) float64 {
+			return actionTable[symmetry.Apply(transform, move)]
+		}
+
+		expected := 0.0
+		probabilities := q.strategy.Probabilities(q.ExplorationRate, moves, value, q.visits(t.FutureState))
+		for i, move := range moves {
+			expected += probabilities[i] * value(move)
+		}
+		return expected
+	default:
+		_, best := q.best(q.Table, t.FutureState, t.FutureMoves)
+		return best
+	}
+}
+
+// update moves the value of an action towards a target and returns the
+// difference to it before, the update is also pushed to a parameter server
+func (q *qlearning) update(table map[string]map[int64]float64, state []int64, action int64, target, weight float64) float64 {
+	hash, transform := q.key(state)
+	actionTable := q.entry(table, hash, len(state))
+	action = symmetry.Apply(transform, action)
+
+	step := q.LearningRate * weight
+	delta := target - actionTable[action]
+	actionTable[action] += step * delta
+
+	if q.params != nil {
+		q.params.Add(hash, action, target, step)
+	}
+	return delta
+}
+
+// learnDouble updates one of the two tables at random, the best future move
+// is chosen by the updated table and valued by the other one
+func (q *qlearning) learnDouble(t replay.Transition, weight float64) float64 {
+	table, other := q.Table, q.Table2
+	if r.Intn(2) == 1 {
+		table, other = other, table
+	}
+
+	target := float64(t.Reward)
+	if !t.Terminal {
+		move, _ := q.best(table, t.FutureState, t.FutureMoves)
+		actionTable, transform := q.getActionTable(other, t.FutureState)
+		target += q.DiscountFactor * actionTable[symmetry.Apply(transform, move)]
+	}
+
+	return q.update(table, t.State, t.Action, target, weight)
+}
+
+// learnTraces updates all actions of the game so far by their eligibility and
+// returns the TD error, the traces end with the game
+func (q *qlearning) learnTraces(t replay.Transition, target float64) float64 {
+	hash, transform := q.key(t.State)
+	actionTable := q.entry(q.Table, hash, len(t.State))
+	action := symmetry.Apply(transform, t.Action)
+
+	delta := target - actionTable[action]
+	q.traces[trace{hash, action}] = 1
+
+	decay := q.DiscountFactor * q.lambda
+	for e, eligibility := range q.traces {
+		q.Table[e.state][e.action] += q.LearningRate * delta * eligibility
+
+		eligibility *= decay
+		if t.Terminal || eligibility < minTrace {
+			delete(q.traces, e)
+		} else {
+			q.traces[e] = eligibility
+		}
+	}
+	return delta
+}
+
+// best returns the legal move with the highest value in a table and its
+// value, all actions are considered if the legal moves are unknown
+func (q *qlearning) best(table map[string]map[int64]float64, state, legalMoves []int64) (int64, float64) {
+	actionTable, transform := q.getActionTable(table, state)
+
+	bestMove := int64(-1)
+	bestValue := -math.MaxFloat64
+	for _, move := range movesOf(state, legalMoves) {
+		if value := actionTable[symmetry.Apply(transform, move)]; value > bestValue {
+			bestValue = value
+			bestMove = move
+		}
+	}
+	return bestMove, bestValue
+}
+
+// movesOf returns the legal moves or all fields if they are unknown
+func movesOf(state, legalMoves []int64) []int64 {
+	if len(legalMoves) > 0 {
+		return legalMoves
+	}
+
+	moves := make([]int64, len(state))
+	for i := range moves {
+		moves[i] = int64(i)
+	}
+	return moves
+}
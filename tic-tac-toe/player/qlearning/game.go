@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/replay"
+)
+
+// game is the agent of a single game, games played concurrently share the
+// learner and hold its lock while they use it
+type game struct {
+	q       *qlearning
+	traces  map[trace]float64  // lent to the learner while the game holds the lock
+	state   []int64            // of the last move
+	pending *replay.Transition // waits for the next action of on-policy algorithms
+}
+
+// newGame starts the exploration rate of the schedule for the next episode
+func (q *qlearning) newGame() client.Agent {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if !q.frozen {
+		q.ExplorationRate = q.schedule.Episode(q.ExplorationRate, q.Games)
+	}
+	return &game{
+		q:      q,
+		traces: make(map[trace]float64),
+	}
+}
+
+// Move trains the pending transition of on-policy algorithms with the action
+// chosen
+func (g *game) Move(t *client.Turn) (int64, error) {
+	q := g.q
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.traces = g.traces
+
+	if q.penalizeInvalid && !q.frozen && len(t.LegalMoves) > 0 {
+		q.penalize(t.State, t.LegalMoves)
+	}
+
+	action := q.makeMove(t.State, t.LegalMoves)
+
+	if g.pending != nil {
+		q.train(*g.pending, action)
+		g.pending = nil
+	}
+
+	g.state = t.State
+	return action, nil
+}
+
+// Observe learns from the result of a move, on-policy algorithms wait for the
+// next action unless the game is over
+func (g *game) Observe(action int64, t *client.Turn) {
+	q := g.q
+	q.m.Lock()
+	defer q.m.Unlock()
+	if q.frozen {
+		return
+	}
+	q.traces = g.traces
+
+	transition := replay.Transition{
+		State:       g.state,
+		Action:      action,
+		Reward:      t.Result,
+		FutureState: t.State,
+		FutureMoves: t.LegalMoves,
+		Terminal:    t.Over(),
+	}
+	if q.onPolicy() && !transition.Terminal {
+		g.pending = &transition
+	} else {
+		q.train(transition, -1)
+	}
+}
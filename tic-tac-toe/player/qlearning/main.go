@@ -3,19 +3,26 @@ package main
 import (
 	"encoding/json"
 	"flag"
-	"fmt"
 	"io/ioutil"
 	"log"
-	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/exploration"
+	"github.com/arenaio/woodhack2018/learning"
 	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/qtable"
+	"github.com/arenaio/woodhack2018/record"
+	"github.com/arenaio/woodhack2018/replay"
+	"github.com/arenaio/woodhack2018/symmetry"
+	"github.com/arenaio/woodhack2018/telemetry"
 )
 
 var r *rand.Rand
@@ -27,56 +34,307 @@ func init() {
 func main() {
 	address := flag.String("address", ":8000", "server address")
 	name := flag.String("name", "Q-Table", "bot name")
-	file := flag.String("file", "", "file with Q-Table data set")
+	opponent := flag.String("opponent", "", "only play against the bot with this name")
+	train := flag.Bool("train", true, "learn from the games played, disable to play with the table and exploration rate as they are")
+	eval := flag.Int("eval", 0, "play this many games greedily without learning and report the results instead of training")
+	algorithm := flag.String("algorithm", qLearning, "learning algorithm: q, sarsa, expected-sarsa, double-q or td-lambda (SARSA with eligibility traces)")
+	lambda := flag.Float64("lambda", 0.8, "decay of the eligibility traces of td-lambda")
+	replaySize := flag.Int("replay", 0, "number of recent transitions to learn from again, 0 disables experience replay, not for on-policy algorithms")
+	replayBatch := flag.Int("replayBatch", 8, "replayed transitions learned after every move")
+	priority := flag.Float64("priority", 0.6, "exponent of the TD errors replayed transitions are sampled by, 0 samples uniformly")
+	priorityCorrection := flag.Float64("priorityCorrection", 0.4, "how much the learning rate of replayed transitions corrects for their priority, 0 not at all, 1 fully")
+	replayRecords := flag.String("replayRecords", "", "record file of a server to fill the replay buffer with, the regular tic-tac-toe games of all players are learned from both sides")
+	paramsAddress := flag.String("params", "", "address of a parameter server to share the table with other actors, it checkpoints the table instead, not for double-q and td-lambda")
+	syncEvery := flag.Int64("syncEvery", 10, "games between pushing updates to the parameter server and pulling its values")
+	penalizeInvalid := flag.Bool("penalizeInvalid", false, "train illegal moves towards the invalid move reward, they are never played")
+	symmetric := flag.Bool("symmetric", true, "learn rotations and reflections of a position as one entry, disable for tables of raw positions")
+	file := flag.String("file", "", "checkpoint to resume from, binary or .json, parameters given as flags take precedence")
+	checkpoint := flag.String("checkpoint", "q-table.qtable", "file to write checkpoints to, the previous ones are kept as file.1, file.2, ...")
+	checkpointEvery := flag.Int64("checkpointEvery", 10000, "games between checkpoints, 0 disables them")
+	keep := flag.Int("keep", 3, "number of previous checkpoints to keep")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time, they share the table")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
+	telemetryFile := flag.String("telemetry", "", "file to append the learning curve to, CSV if it ends in .csv and JSON lines otherwise")
+	telemetryEvery := flag.Int64("telemetryEvery", 1000, "games between rows of the learning curve")
+	telemetryWindow := flag.Int("telemetryWindow", 1000, "number of recent games the rates of the learning curve are taken over")
+	strategy := flag.String("exploration", exploration.EpsilonGreedy, "exploration strategy: epsilon (random moves), softmax (by action values) or ucb (by visit counts)")
+	schedule := flag.String("schedule", exploration.PerMove, "schedule of the exploration rate: move (decay after every move), linear or exponential (by episode)")
+	explorationRate := flag.Float64("explorationRate", 1, "initial exploration rate: probability of a random move, temperature of softmax or exploration constant of ucb")
+	explorationDecay := flag.Float64("explorationDecay", 0.99999, "factor the exploration rate is multiplied with after every move, or every episode for exponential schedules")
+	explorationEnd := flag.Float64("explorationEnd", 0, "final exploration rate of linear and exponential schedules")
+	explorationEpisodes := flag.Int64("explorationEpisodes", 100000, "episodes of a linear schedule to reach the final exploration rate")
+	learningRate := flag.Float64("learningRate", 0.001, "weight of a new estimate of an action value")
+	discountFactor := flag.Float64("discountFactor", 1, "weight of future rewards")
 	flag.Parse()
 
 	q := &qlearning{
-		Table:           make(map[string]map[int64]float64),
-		ExplorationRate: 1,
-		LearningRate:    0.001,
-		DiscountFactor:  1,
+		Table:            make(map[string]map[int64]float64),
+		ExplorationRate:  *explorationRate,
+		ExplorationDecay: *explorationDecay,
+		LearningRate:     *learningRate,
+		DiscountFactor:   *discountFactor,
+	}
+	q.penalizeInvalid = *penalizeInvalid
+	q.frozen = !*train
+	if *symmetric {
+		q.symmetries = symmetry.Nested(1)
+	}
+	var err error
+	q.strategy, err = exploration.NewStrategy(*strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *algorithm {
+	case qLearning, sarsa, expectedSarsa, doubleQ, tdLambda:
+		q.algorithm = *algorithm
+		q.lambda = *lambda
+	default:
+		log.Fatalf("unknown algorithm %s", *algorithm)
+	}
+	if *replaySize > 0 {
+		if q.onPolicy() {
+			log.Fatalf("experience replay does not work with the on-policy algorithm %s", q.algorithm)
+		}
+		q.replay = replay.New(*replaySize, *priority)
+		q.replayBatch = *replayBatch
+		q.priorityCorrection = *priorityCorrection
 	}
 
 	if len(*file) > 0 {
 		log.Printf("Fetching from state file: %s", *file)
 		q.fetchFromFile(*file)
+		log.Printf("Resuming after %d games", q.Games)
+
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "explorationRate":
+				q.ExplorationRate = *explorationRate
+			case "explorationDecay":
+				q.ExplorationDecay = *explorationDecay
+			case "learningRate":
+				q.LearningRate = *learningRate
+			case "discountFactor":
+				q.DiscountFactor = *discountFactor
+			}
+		})
+	}
+	if q.algorithm != doubleQ {
+		q.Table2 = nil
+	} else if q.Table2 == nil {
+		q.Table2 = make(map[string]map[int64]float64)
+	}
+	if q.strategy.String() != exploration.UCB {
+		q.Visits = nil
+	} else if q.Visits == nil {
+		q.Visits = make(map[string]map[int64]float64)
 	}
 
-	conn, err := grpc.Dial(*address, grpc.WithInsecure())
+	q.schedule, err = exploration.NewSchedule(*schedule, *explorationRate, *explorationEnd, q.ExplorationDecay, *explorationEpisodes)
 	if err != nil {
-		log.Fatalf("unable to connect on port %s: %s", *address, err)
+		log.Fatal(err)
+	}
+	log.Printf("Exploring by %s, schedule: %s", q.strategy, q.schedule)
+
+	if len(*replayRecords) > 0 && q.replay != nil {
+		games, err := q.replayRecords(*replayRecords)
+		if err != nil {
+			log.Fatalf("unable to read records: %s", err)
+		}
+		log.Printf("Replaying %d transitions of %d recorded games", q.replay.Len(), games)
 	}
-	defer conn.Close()
 
-	client := proto.NewTicTacToeClient(conn)
+	runner, err := client.NewRunner(*address, *name, proto.RegularTicTacToe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer runner.Close()
+	runner.LogGames = *logGames
+	runner.Opponent = *opponent
 	ctx := context.Background()
 
-	for gameCount := 1; ; gameCount++ {
-		q.runGameOnServer(client, ctx, *name)
+	if len(*paramsAddress) > 0 {
+		if q.algorithm == doubleQ || q.algorithm == tdLambda {
+			log.Fatalf("the parameter server does not work with %s", q.algorithm)
+		}
+
+		q.params, err = learning.DialParams(*paramsAddress)
+		if err != nil {
+			log.Fatalf("unable to connect to the parameter server on %s: %s", *paramsAddress, err)
+		}
+		defer q.params.Close()
 
-		if gameCount%1000 == 0 {
-			log.Printf("%d Episodes - Exploration Rate: %.4f", gameCount, q.ExplorationRate)
+		if err := q.params.Sync(ctx, q.Table, q.header()); err != nil {
+			log.Fatalf("unable to pull the table: %s", err)
 		}
+		log.Printf("Pulled %d states from the parameter server", len(q.Table))
+	}
+
+	if *eval > 0 {
+		q.frozen = true
+		q.ExplorationRate = 0
+		if err := learning.Evaluate(ctx, runner, q.newGame, *eval, *concurrency); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(*telemetryFile) > 0 && *telemetryEvery > 0 {
+		q.telemetry, err = telemetry.New(*telemetryFile, *telemetryWindow)
+		if err != nil {
+			log.Fatalf("unable to open %s: %s", *telemetryFile, err)
+		}
+		defer q.telemetry.Close()
+	}
+
+	// games are counted on from the loaded table, which keeps its count if frozen
+	results := make(map[int64]int)
+	games := q.Games
+	runner.OnGame = func(g client.Game) {
+		q.m.Lock()
+		defer q.m.Unlock()
+
+		results[g.Result]++
+		games++
+		if !q.frozen {
+			q.Games = games
+		}
+
+		if q.telemetry != nil {
+			q.telemetry.Episode(g.Result, g.Moves, g.InvalidMoves)
+			if games%*telemetryEvery == 0 {
+				if err := q.telemetry.Write(games, q.ExplorationRate, len(q.Table)); err != nil {
+					log.Fatalf("unable to write to %s: %s", *telemetryFile, err)
+				}
+			}
+		}
+
+		if games%1000 == 0 {
+			log.Printf(
+				"%d Episodes - Exploration Rate (%s): %.4f - won / draw / lost: %d / %d / %d",
+				games,
+				q.strategy,
+				q.ExplorationRate,
+				results[proto.Won],
+				results[proto.Draw],
+				results[proto.Lost],
+			)
+			results = make(map[int64]int)
+		}
+
+		// a frozen table has nothing to share or save
+		if q.frozen {
+			return
+		}
+		if q.params != nil {
+			q.params.Game()
+			if q.Games%*syncEvery == 0 {
+				if err := q.params.Sync(ctx, q.Table, q.header()); err != nil {
+					log.Fatalf("unable to sync with the parameter server: %s", err)
+				}
+			}
+		} else if *checkpointEvery > 0 && q.Games%*checkpointEvery == 0 {
+			q.storeTable(*checkpoint, *keep)
+			log.Printf("%s saved after %d games", *checkpoint, q.Games)
+		}
+	}
+
+	if err := runner.RunConcurrent(ctx, q.newGame, 0, *concurrency); err != nil {
+		log.Fatal(err)
 	}
 }
 
 type qlearning struct {
-	Table           map[string]map[int64]float64 `json:"Table"`
-	ExplorationRate float64                      `json:"ExplorationRate"`
-	LearningRate    float64                      `json:"LearningRate"`
-	DiscountFactor  float64                      `json:"DiscountFactor"`
+	Table            map[string]map[int64]float64 `json:"Table"`
+	Table2           map[string]map[int64]float64 `json:"Table2,omitempty"` // second estimate of double Q-learning
+	Visits           map[string]map[int64]float64 `json:"Visits,omitempty"` // of the actions for UCB exploration
+	Games            int64                        `json:"Games,omitempty"`
+	ExplorationRate  float64                      `json:"ExplorationRate"`
+	ExplorationDecay float64                      `json:"ExplorationDecay,omitempty"`
+	LearningRate     float64                      `json:"LearningRate"`
+	DiscountFactor   float64                      `json:"DiscountFactor"`
+
+	m sync.Mutex // guards the learner against games played concurrently
+
+	symmetries      [][]int64 // positions are keyed by their canonical form if set
+	strategy        exploration.Strategy
+	schedule        exploration.Schedule
+	penalizeInvalid bool
+	frozen          bool // the table is not updated while evaluating or without -train
+	algorithm       string
+	lambda          float64
+	traces          map[trace]float64 // eligibility of the actions of the game holding the lock
+
+	params             *learning.Params // nil without a parameter server
+	replay             *replay.Buffer   // nil without experience replay
+	replayBatch        int
+	priorityCorrection float64
+
+	telemetry *telemetry.Recorder // nil without a learning curve
 }
 
-func (q *qlearning) storeTable(gameCount int) {
-	bytes, _ := json.Marshal(q)
+// header returns the parameters stored with the table
+func (q *qlearning) header() qtable.Header {
+	return qtable.Header{
+		Fields:           9,
+		Games:            q.Games,
+		ExplorationRate:  q.ExplorationRate,
+		ExplorationDecay: q.ExplorationDecay,
+		LearningRate:     q.LearningRate,
+		DiscountFactor:   q.DiscountFactor,
+	}
+}
 
-	err := ioutil.WriteFile(fmt.Sprintf("./%d.json", gameCount), bytes, 0644)
+func (q *qlearning) storeTable(path string, keep int) {
+	h := q.header()
+	err := qtable.Checkpoint(path, keep, h, q.Table)
+	if err == nil && q.Table2 != nil {
+		err = qtable.Checkpoint(siblingPath(path, "b"), keep, h, q.Table2)
+	}
+	if err == nil && q.Visits != nil {
+		err = qtable.Checkpoint(siblingPath(path, "visits"), keep, h, q.Visits)
+	}
 	if err != nil {
-		log.Printf("Error storing table %d: %s", gameCount, err)
+		log.Printf("Error storing table after %d games: %s", q.Games, err)
 	}
 }
 
+// siblingPath returns the file of another table stored next to the Q-table,
+// the second one of double Q-learning in q-table.b.qtable for example
+func siblingPath(path, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// fetchFromFile loads a binary table or a JSON one if the file ends in .json,
+// the exploration decay is kept if the file does not contain it. The second
+// table of double Q-learning and the visit counts of UCB are loaded from their
+// own files if they exist.
 func (q *qlearning) fetchFromFile(filePath string) {
+	if !strings.HasSuffix(filePath, ".json") {
+		h, table, err := qtable.Load(filePath)
+		if err != nil {
+			log.Printf("Error loading state file: %s, %s", filePath, err)
+			os.Exit(1)
+		}
+
+		q.Table = table
+		q.Games = h.Games
+		q.ExplorationRate = h.ExplorationRate
+		q.LearningRate = h.LearningRate
+		q.DiscountFactor = h.DiscountFactor
+		if h.ExplorationDecay > 0 {
+			q.ExplorationDecay = h.ExplorationDecay
+		}
+
+		if q.algorithm == doubleQ {
+			q.Table2 = loadSibling(filePath, "b")
+		}
+		if q.strategy.String() == exploration.UCB {
+			q.Visits = loadSibling(filePath, "visits")
+		}
+		return
+	}
+
 	raw, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		log.Printf("Error loading state file: %s, %s", filePath, err)
@@ -86,6 +344,16 @@ func (q *qlearning) fetchFromFile(filePath string) {
 	json.Unmarshal(raw, &q)
 }
 
+// loadSibling loads a table stored next to the Q-table, nil if there is none
+func loadSibling(path, name string) map[string]map[int64]float64 {
+	_, table, err := qtable.Load(siblingPath(path, name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error loading state file: %s, %s", siblingPath(path, name), err)
+		os.Exit(1)
+	}
+	return table
+}
+
 func hashState(state []int64) string {
 	stateStr := make([]string, len(state))
 	for i, v := range state {
@@ -94,110 +362,178 @@ func hashState(state []int64) string {
 	return strings.Join(stateStr, "")
 }
 
-func (q *qlearning) train(lastState []int64, action int64, futureState []int64, reward int64) {
-	actionTable := q.getActionTable(lastState)
-	futureActionTable := q.getActionTable(futureState)
-
-	estimatedOptimalFuture := float64(proto.InvalidMove)
-	for _, qvalue := range futureActionTable {
-		if qvalue > estimatedOptimalFuture {
-			estimatedOptimalFuture = qvalue
-		}
+// train learns from a transition, replays a batch of stored ones if enabled
+// and updates the exploration rate
+func (q *qlearning) train(t replay.Transition, nextAction int64) {
+	delta := q.learn(t, nextAction, 1)
+	if q.telemetry != nil {
+		q.telemetry.TDError(delta)
 	}
 
-	learnedValue := float64(reward) + q.DiscountFactor*estimatedOptimalFuture
-	actionTable[action] = (1-q.LearningRate)*actionTable[action] + q.LearningRate*learnedValue
+	if q.replay != nil {
+		q.replay.Add(t)
+		q.learnReplayed()
+	}
 
-	q.ExplorationRate *= 0.99999
+	q.ExplorationRate = q.schedule.Move(q.ExplorationRate)
 }
 
-func (q *qlearning) runGameOnServer(client proto.TicTacToeClient, ctx context.Context, name string) {
-	//log.Print("Starting new game")
-	stateResult, err := client.NewGame(ctx, &proto.New{GameType: proto.RegularTicTacToe, Name: name})
-	if err != nil {
-		log.Fatalf("creating game failed: %s", err)
+// learnReplayed learns a batch of stored transitions again and updates their
+// priorities
+func (q *qlearning) learnReplayed() {
+	indices, weights := q.replay.Sample(r, q.replayBatch, q.priorityCorrection)
+	for k, i := range indices {
+		delta := q.learn(q.replay.Get(i), -1, weights[k])
+		q.replay.Update(i, delta)
+		if q.telemetry != nil {
+			q.telemetry.TDError(delta)
+		}
 	}
+}
 
-	//displayState(stateResult.State)
-
-	id := stateResult.Id
-	ongoingGame := true
-
-	for ongoingGame {
-		action := q.makeMove(stateResult.State)
-		//print("\nMoving to: ", action, "\n")
-
-		lastState := stateResult.State
+// replayRecords adds the regular tic-tac-toe games of a record file to the
+// replay buffer from the view of both players and returns their number
+func (q *qlearning) replayRecords(path string) (int, error) {
+	games := 0
+	err := record.Read(path, func(rec record.Record) error {
+		if rec.GameType != proto.RegularTicTacToe {
+			return nil
+		}
 
-		stateResult, err = client.Move(ctx, &proto.Action{Id: id, Move: action})
+		for _, t := range replay.Episodes(plies(rec.Moves), 1, rec.Result) {
+			q.replay.Add(t)
+		}
+		games++
+		return nil
+	})
+	return games, err
+}
 
-		if err != nil {
-			log.Fatal(err)
+// plies replays the moves of a regular tic-tac-toe game, the positions are
+// from the view of the player to move as sent by the server
+func plies(moves []int64) []replay.Ply {
+	state := make([]int64, 9)
+	plies := make([]replay.Ply, len(moves))
+	for i, move := range moves {
+		view := make([]int64, len(state))
+		copy(view, state)
+		plies[i] = replay.Ply{State: view, LegalMoves: emptyFields(state), Move: move}
+
+		// switch to the view of the opponent
+		state[move] = 1
+		for field := range state {
+			state[field] = -state[field]
 		}
+	}
+	return plies
+}
 
-		// don't train when the exploration rate is set to zero
-		if q.ExplorationRate > 0 {
-			q.train(lastState, action, stateResult.State, stateResult.Result)
+func emptyFields(state []int64) []int64 {
+	var fields []int64
+	for field, v := range state {
+		if v == 0 {
+			fields = append(fields, int64(field))
 		}
+	}
+	return fields
+}
+
+// penalize trains the moves that are not legal in a state towards the
+// invalid move reward as if they were rejected by the server
+func (q *qlearning) penalize(state, legalMoves []int64) {
+	legal := make(map[int64]bool, len(legalMoves))
+	for _, move := range legalMoves {
+		legal[move] = true
+	}
+
+	tables := []map[string]map[int64]float64{q.Table}
+	if q.Table2 != nil {
+		tables = append(tables, q.Table2)
+	}
 
-		switch stateResult.Result {
-		case proto.InvalidMove:
-			//print("Made an illegal move\n")
-		case proto.Won:
-			//print("Won the game!\n")
-			ongoingGame = false
-		case proto.Lost:
-			//print("Lost the game!\n")
-			ongoingGame = false
-		case proto.Draw:
-			//print("Draw game!\n")
-			ongoingGame = false
-		default:
-			// valid move
-			//displayState(stateResult.State)
+	for move := int64(0); move < int64(len(state)); move++ {
+		if !legal[move] {
+			for _, table := range tables {
+				_, future := q.best(table, state, legalMoves)
+				q.update(table, state, move, float64(proto.InvalidMove)+q.DiscountFactor*future, 1)
+			}
 		}
 	}
+}
 
-	//displayState(stateResult.State)
+// getActionTable returns the action values of the canonical form of a state
+// in a table and the transformation of actions to it
+func (q *qlearning) getActionTable(table map[string]map[int64]float64, state []int64) (map[int64]float64, []int64) {
+	hash, transform := q.key(state)
+	return q.entry(table, hash, len(state)), transform
 }
 
-func (q *qlearning) getActionTable(state []int64) map[int64]float64 {
-	hash := hashState(state)
+// key returns the key of the canonical form of a state and the
+// transformation of actions to it
+func (q *qlearning) key(state []int64) (string, []int64) {
+	canonical, transform := symmetry.Canonical(state, q.symmetries)
+	return hashState(canonical), transform
+}
 
-	actionTable, found := q.Table[hash]
+// entry returns the action values stored under a key, they are added if the
+// state is new
+func (q *qlearning) entry(table map[string]map[int64]float64, hash string, fields int) map[int64]float64 {
+	actionTable, found := table[hash]
 	if !found {
 		actionTable = make(map[int64]float64)
-		for i := 0; i < len(state); i++ {
+		for i := 0; i < fields; i++ {
 			actionTable[int64(i)] = 0
 		}
-		q.Table[hash] = actionTable
+		table[hash] = actionTable
 	}
 
 	return actionTable
 }
 
-func (q *qlearning) makeMove(state []int64) int64 {
-	actionTable := q.getActionTable(state)
+// actionValues returns the estimate of the value of the actions of a state,
+// the sum of both tables for double Q-learning
+func (q *qlearning) actionValues(state []int64) func(int64) float64 {
+	actionTable, transform := q.getActionTable(q.Table, state)
+	if q.Table2 == nil {
+		return func(action int64) float64 {
+			return actionTable[symmetry.Apply(transform, action)]
+		}
+	}
 
-	//displayState(state)
+	actionTable2, _ := q.getActionTable(q.Table2, state)
+	return func(action int64) float64 {
+		action = symmetry.Apply(transform, action)
+		return actionTable[action] + actionTable2[action]
+	}
+}
 
-	if r.Float64() < q.ExplorationRate {
-		//log.Printf("Explore (%.2f)", q.ExplorationRate)
-		return int64(r.Intn(len(state)))
+// makeMove picks one of the legal moves by the exploration strategy, all
+// fields are tried if the server does not provide them
+func (q *qlearning) makeMove(state, legalMoves []int64) int64 {
+	moves := movesOf(state, legalMoves)
+	probabilities := q.strategy.Probabilities(q.ExplorationRate, moves, q.actionValues(state), q.visits(state))
+	move := exploration.Sample(r, moves, probabilities)
+
+	if q.Visits != nil && !q.frozen {
+		hash, transform := q.key(state)
+		q.entry(q.Visits, hash, len(state))[symmetry.Apply(transform, move)]++
 	}
 
-	//log.Printf("I know what's best... (%.2f)", q.ExplorationRate)
-	bestMove := int64(-1)
-	bestValue := -math.MaxFloat64
-	for action, value := range actionTable {
-		// log.Printf("current: %f, best: %f, action: %d, best: %d", value, bestValue, action, bestMove)
-		if value > bestValue {
-			bestValue = value
-			bestMove = action
-		}
+	return move
+}
+
+// visits returns how often the actions of a state were played, they are only
+// counted for UCB exploration
+func (q *qlearning) visits(state []int64) func(int64) float64 {
+	if q.Visits == nil {
+		return func(int64) float64 { return 0 }
 	}
 
-	return bestMove
+	hash, transform := q.key(state)
+	counts := q.Visits[hash]
+	return func(action int64) float64 {
+		return counts[symmetry.Apply(transform, action)]
+	}
 }
 
 func displayState(state []int64) {
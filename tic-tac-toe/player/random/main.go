@@ -4,16 +4,18 @@ import (
 	"flag"
 	"log"
 	"math/rand"
+	"sync"
 
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 
+	"github.com/arenaio/woodhack2018/client"
 	"github.com/arenaio/woodhack2018/proto"
 )
 
 var r *rand.Rand
 var clientType int
 var clientName string
+var m sync.Mutex // guards r against games played concurrently
 
 func init() {
 	r = rand.New(rand.NewSource(199))
@@ -22,6 +24,8 @@ func init() {
 func main() {
 	clientTypePtr := flag.Int("type", 1, "an int")
 	address := flag.String("address", ":8000", "server address")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
 	flag.Parse()
 
 	clientType = *clientTypePtr
@@ -35,72 +39,30 @@ func main() {
 		clientName = "Random3"
 	}
 
-	conn, err := grpc.Dial(*address, grpc.WithInsecure())
+	runner, err := client.NewRunner(*address, clientName, proto.RegularTicTacToe)
 	if err != nil {
-		log.Fatalf("unable to connect on port %s: %s", *address, err)
+		log.Fatal(err)
 	}
-	defer conn.Close()
+	defer runner.Close()
+	runner.LogGames = *logGames
 
-	client := proto.NewTicTacToeClient(conn)
-	ctx := context.Background()
-
-	for {
-		runGameOnServer(client, ctx)
+	newAgent := func() client.Agent { return Random{} }
+	if err := runner.RunConcurrent(context.Background(), newAgent, 0, *concurrency); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func runGameOnServer(client proto.TicTacToeClient, ctx context.Context) {
-	//log.Print("Starting new game")
-	stateResult, err := client.NewGame(ctx, &proto.New{GameType: proto.RegularTicTacToe, Name: clientName})
-	if err != nil {
-		log.Fatalf("creating game failed: %s", err)
-	}
-
-	id := stateResult.Id
-	ongoingGame := true
-	turnCount := 0
-	fieldCount := len(stateResult.State)
-	for {
-		switch stateResult.Result {
-		case proto.InvalidMove:
-			// invalid move
-			//print("Made an illegal move\n")
-			break
-		case proto.Won:
-			// game won
-			//print("Won the game!\n")
-			ongoingGame = false
-			break
-		case proto.Lost:
-			//print("Lost the game!\n")
-			// game lost
-			ongoingGame = false
-			break
-		case proto.Draw:
-			//print("Draw game!\n")
-			ongoingGame = false
-		default:
-			// valid move
-			turnCount++
-			//displayState(stateResult.State)
-		}
+// Random moves by the client type
+type Random struct{}
 
-		if !ongoingGame || turnCount > fieldCount {
-			//displayState(stateResult.State)
-			break
-		}
-
-		moveTarget := makeMove(stateResult.State)
-		//print("\nMoving to: ", moveTarget, "\n")
-		stateResult, err = client.Move(ctx, &proto.Action{Id: id, Move: moveTarget})
-
-		if err != nil {
-			log.Fatal(err)
-		}
-		//time.Sleep(100 * time.Millisecond)
-	}
+func (Random) Move(t *client.Turn) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	return makeMove(t.State), nil
 }
 
+func (Random) Observe(move int64, t *client.Turn) {}
+
 func makeMove(state []int64) int64 {
 	var moveTarget int
 	switch clientType {
@@ -13,34 +13,77 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/record"
+	"github.com/arenaio/woodhack2018/seats"
+	"github.com/arenaio/woodhack2018/tic-tac-toe/minimax"
 )
 
 func main() {
 	address := flag.String("address", ":8000", "server address")
+	notaktoBoards := flag.Int64("notaktoBoards", 3, "number of boards in a game of Notakto")
+	seatPolicy := flag.String("seats", seats.Join, "who moves first: join, random, alternate or balanced")
+	recordFile := flag.String("records", "", "file to append the records of finished games to")
+	minimaxName := flag.String("minimax", "", "name of a built-in perfect player of regular tic-tac-toe, it plays everyone asking for it as opponent")
 	flag.Parse()
 
+	if *notaktoBoards < 1 {
+		log.Fatalf("invalid number of Notakto boards %d, it has to be at least 1", *notaktoBoards)
+	}
+
+	seatAssigner, err := seats.NewAssigner(*seatPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var records *record.Writer
+	if len(*recordFile) > 0 {
+		records, err = record.Create(*recordFile)
+		if err != nil {
+			log.Fatalf("unable to open record file: %s", err)
+		}
+		defer records.Close()
+	}
+
 	listener, err := net.Listen("tcp", *address)
 	if err != nil {
 		log.Fatalf("unable to listen on port %s: %v", *address, err)
 	}
 
 	srv := grpc.NewServer()
-	proto.RegisterTicTacToeServer(srv, NewServer())
+	proto.RegisterTicTacToeServer(srv, NewServer(*notaktoBoards, seatAssigner, records, *minimaxName))
 	log.Printf("listening on %s", *address)
 	log.Print(srv.Serve(listener))
 }
 
 type Server struct {
-	games        map[int64]*Game
+	games        map[int64]*Game // by player id
+	waiting      []*Game         // games waiting for a second player, oldest first
 	m            sync.Mutex
 	nextPlayerId int64
-	stats        map[string]*Stats
+	nextGameId   int64
+	stats        map[string]*PlayerStats
+	seats        *seats.Assigner
+	records      *record.Writer
+	minimax      *minimax.Player // built-in opponent, nil if disabled
+	minimaxName  string
+
+	notaktoBoards int64
 }
 
-func NewServer() *Server {
+func NewServer(notaktoBoards int64, seatAssigner *seats.Assigner, records *record.Writer, minimaxName string) *Server {
+	var player *minimax.Player
+	if len(minimaxName) > 0 {
+		player = minimax.New(true)
+	}
+
 	return &Server{
-		games: make(map[int64]*Game),
-		stats: make(map[string]*Stats),
+		games:         make(map[int64]*Game),
+		stats:         make(map[string]*PlayerStats),
+		seats:         seatAssigner,
+		records:       records,
+		minimax:       player,
+		minimaxName:   minimaxName,
+		notaktoBoards: notaktoBoards,
 	}
 }
 
@@ -53,15 +96,14 @@ func (s *Server) String() string {
 }
 
 func (s *Server) getStats() string {
-	stats := []string{"Current Standing"}
+	stats := []string{"Current Standing (won / draw / lost)"}
 	for name, stat := range s.stats {
 		stats = append(stats, fmt.Sprintf(
-			"%s\t%.0f%% (%d / %d / %d)",
+			"%s\t%s\tfirst: %s\tsecond: %s",
 			name,
-			float64(stat.won)/float64(stat.won+stat.draw+stat.lost)*100,
-			stat.won,
-			stat.draw,
-			stat.lost,
+			stat.Stats,
+			stat.seats[0],
+			stat.seats[1],
 		))
 	}
 
@@ -72,7 +114,7 @@ func (s *Server) NewGame(ctx context.Context, new *proto.New) (*proto.StateResul
 	//log.Printf("Server.NewGame(%d)", new.GameType)
 
 	switch new.GameType {
-	case proto.RegularTicTacToe:
+	case proto.RegularTicTacToe, proto.ConnectFour, proto.MisereTicTacToe, proto.Notakto, proto.Qubic:
 		break
 	default:
 		return nil, errors.New(fmt.Sprintf(
@@ -81,73 +123,117 @@ func (s *Server) NewGame(ctx context.Context, new *proto.New) (*proto.StateResul
 		))
 	}
 
+	if s.minimax != nil && new.GameType == proto.RegularTicTacToe && new.Opponent == s.minimaxName && new.Name != s.minimaxName {
+		go s.playMinimax(new.Name)
+	}
+
 	s.m.Lock()
 	_, found := s.stats[new.Name]
 	if !found {
-		s.stats[new.Name] = &Stats{}
+		s.stats[new.Name] = &PlayerStats{}
 	}
 
 	playerId := s.nextPlayerId
 
-	var g *Game
-	var ok bool
-
 	if playerId%1000 == 1 {
 		log.Print(s.getStats())
 	}
 
-	if playerId%2 == 0 {
-		g = &Game{
-			p1:     playerId,
-			p1Name: new.Name,
-			p2:     -1,
-			state:  make([]int64, 9),
-			turn:   1,
-			d1:     make(chan struct{}),
-			d2:     make(chan struct{}),
-		}
-		s.games[playerId/2] = g
+	g := s.findGame(new)
+	if g == nil {
+		g = NewGame(s.nextGameId, new.GameType, s.notaktoBoards)
+		g.p1 = playerId
+		g.p1Name = new.Name
+		g.opponent = new.Opponent
+		s.waiting = append(s.waiting, g)
+		s.nextGameId++
 		//log.Printf("new game created: %s", g)
 	} else {
-		//log.Printf("trying to join game: %d", (playerId-1)/2)
-		g, ok = s.games[(playerId-1)/2]
-		if !ok {
-			return nil, errors.New("game not found")
-		}
 		g.p2 = playerId
 		g.p2Name = new.Name
+		if !s.seats.Assign(g.p1Name, g.p2Name) {
+			g.p1, g.p2 = g.p2, g.p1
+			g.p1Name, g.p2Name = g.p2Name, g.p1Name
+		}
+		close(g.ready)
 		//log.Printf("found game: %s", g)
 	}
+	s.games[playerId] = g
 
 	s.nextPlayerId++
 	s.m.Unlock()
 
-	if playerId%2 != 0 {
+	<-g.ready // the seats are assigned once the second player joined
+	if playerId != g.p1 {
 		g.WaitForPlayer1() // since player 1 begins the game wait for first move
 	}
 
 	return &proto.StateResult{
-		Id:     playerId,
-		State:  mapOutput(g.state, playerId == g.p1),
-		Result: proto.ValidMove,
+		Id:         playerId,
+		Seat:       g.seat(playerId),
+		State:      g.output(playerId),
+		Result:     proto.ValidMove,
+		LastMove:   g.lastMove(),
+		LegalMoves: g.legalMoves(),
 	}, nil
 }
 
+// playMinimax plays a game of the built-in minimax player against a player
+func (s *Server) playMinimax(opponent string) {
+	ctx := context.Background()
+	stateResult, err := s.NewGame(ctx, &proto.New{GameType: proto.RegularTicTacToe, Name: s.minimaxName, Opponent: opponent})
+	if err != nil {
+		log.Printf("built-in player unable to start a game: %s", err)
+		return
+	}
+
+	id := stateResult.Id
+	for stateResult.Result == proto.ValidMove {
+		stateResult, err = s.Move(ctx, &proto.Action{Id: id, Move: s.minimax.Move(stateResult.State)})
+		if err != nil {
+			log.Printf("built-in player unable to move: %s", err)
+			return
+		}
+	}
+}
+
+// findGame removes and returns the oldest waiting game of the type the new
+// player can join, both players can ask for an opponent by name. Bots of the
+// same name only play each other if one of them asks for itself as the
+// opponent.
+func (s *Server) findGame(new *proto.New) *Game {
+	for i, g := range s.waiting {
+		if g.gameType != new.GameType {
+			continue
+		}
+		if (len(g.opponent) > 0 && g.opponent != new.Name) || (len(new.Opponent) > 0 && new.Opponent != g.p1Name) {
+			continue
+		}
+		// games of a bot playing concurrently would be paired with each other
+		if len(new.Name) > 0 && g.p1Name == new.Name && g.opponent != new.Name && new.Opponent != new.Name {
+			continue
+		}
+
+		s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+		return g
+	}
+	return nil
+}
+
 func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult, error) {
 	//log.Printf("Server.Move(Id: %d, Move: %d)", a.Id, a.Move)
 
-	gameId := (a.Id - a.Id%2) / 2
-	//log.Printf("Looking for game #%d", gameId)
-
-	g, ok := s.games[gameId]
+	s.m.Lock()
+	g, ok := s.games[a.Id]
+	s.m.Unlock()
 	if !ok {
-		log.Printf("game #%d not found", gameId)
+		log.Printf("game of player #%d not found", a.Id)
 		return nil, errors.New("game not found")
 	}
 	//log.Printf("game found: %s", g)
 
 	if g.isOver() {
-		log.Printf("game #%d is already over", gameId)
+		log.Printf("game #%d is already over", g.id)
 		return nil, errors.New("game is already over")
 	}
 
@@ -157,37 +243,45 @@ func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult,
 		return nil, errors.New("it's not your turn")
 	}
 
-	if g.state[a.Move] != 0 {
+	cell := g.cell(a.Move)
+	if cell < 0 {
 		//log.Printf("player #%d tried to make an invalid move (%d)", a.Id, a.Move)
 		return &proto.StateResult{
-			Id:     a.Id,
-			State:  mapOutput(g.state, isFirstPlayer),
-			Result: proto.InvalidMove,
+			Id:         a.Id,
+			Seat:       g.seat(a.Id),
+			State:      g.output(a.Id),
+			Result:     proto.InvalidMove,
+			LastMove:   g.lastMove(),
+			LegalMoves: g.legalMoves(),
 		}, nil
 	}
 
-	g.state[a.Move] = g.turn
+	g.state[cell] = g.turn
+	if g.gameType == proto.Notakto {
+		g.state[cell] = 1 // both players place the same pieces
+	}
 	g.turn = 3 - g.turn
+	g.moves = append(g.moves, a.Move)
 
 	//log.Printf("Game had received move: %s", g)
 
 	if g.IsWon(a.Id) {
 		if isFirstPlayer {
-			s.stats[g.p1Name].won++
-			s.stats[g.p2Name].lost++
+			s.addResult(g, proto.Won)
 			//log.Printf("Game %d is won by %s", gameId, g.p1Name)
 			g.Player1Done()
 		} else {
-			s.stats[g.p1Name].lost++
-			s.stats[g.p2Name].won++
+			s.addResult(g, proto.Lost)
 			//log.Printf("Game %d is won by %s", gameId, g.p2Name)
 			g.Player2Done()
 		}
 
 		return &proto.StateResult{
-			Id:     a.Id,
-			State:  mapOutput(g.state, a.Id == g.p1),
-			Result: proto.Won,
+			Id:       a.Id,
+			Seat:     g.seat(a.Id),
+			State:    g.output(a.Id),
+			Result:   proto.Won,
+			LastMove: g.lastMove(),
 		}, nil
 	}
 
@@ -197,12 +291,32 @@ func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult,
 		} else {
 			g.Player2Done()
 		}
-		s.stats[g.p1Name].draw++
-		s.stats[g.p2Name].draw++
+		s.addResult(g, proto.Draw)
 		return &proto.StateResult{
-			Id:     a.Id,
-			State:  mapOutput(g.state, a.Id == g.p1),
-			Result: proto.Draw,
+			Id:       a.Id,
+			Seat:     g.seat(a.Id),
+			State:    g.output(a.Id),
+			Result:   proto.Draw,
+			LastMove: g.lastMove(),
+		}, nil
+	}
+
+	if g.IsLost(a.Id) {
+		if isFirstPlayer {
+			s.addResult(g, proto.Lost)
+			//log.Printf("Game %d is lost by %s", g.id, g.p1Name)
+			g.Player1Done()
+		} else {
+			s.addResult(g, proto.Won)
+			//log.Printf("Game %d is lost by %s", g.id, g.p2Name)
+			g.Player2Done()
+		}
+		return &proto.StateResult{
+			Id:       a.Id,
+			Seat:     g.seat(a.Id),
+			State:    g.output(a.Id),
+			Result:   proto.Lost,
+			LastMove: g.lastMove(),
 		}, nil
 	}
 
@@ -215,7 +329,10 @@ func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult,
 	}
 
 	result := proto.ValidMove
-	if g.IsLost(a.Id) {
+	if g.IsWon(a.Id) {
+		// the opponent's move can decide the game in our favour, e.g. in misère
+		result = proto.Won
+	} else if g.IsLost(a.Id) {
 		//if isFirstPlayer {
 		//	log.Printf("Game %d is lost for %s", gameId, g.p1Name)
 		//} else {
@@ -228,12 +345,24 @@ func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult,
 	}
 
 	return &proto.StateResult{
-		Id:     a.Id,
-		State:  mapOutput(g.state, a.Id == g.p1),
-		Result: result,
+		Id:         a.Id,
+		Seat:       g.seat(a.Id),
+		State:      g.output(a.Id),
+		Result:     result,
+		LastMove:   g.lastMove(),
+		LegalMoves: g.legalMoves(),
 	}, nil
 }
 
+// output maps the state to the perspective of the given player
+func (g Game) output(pId int64) []int64 {
+	if g.gameType == proto.Notakto {
+		// pieces are shared, so they are the same for both players
+		return mapOutput(g.state, true)
+	}
+	return mapOutput(g.state, pId == g.p1)
+}
+
 // map user numbers to me: 1 and -1: opponent
 func mapOutput(state []int64, p1Active bool) []int64 {
 	var mapping map[int64]int64
@@ -251,20 +380,98 @@ func mapOutput(state []int64, p1Active bool) []int64 {
 }
 
 type Game struct {
+	id             int64
+	gameType       int64
 	p1, p2         int64
 	p1Name, p2Name string
+	opponent       string // the only player the creator of the game wants to play
 	d1, d2         chan struct{}
+	ready          chan struct{} // closed once the seats are assigned
 	state          []int64
+	moves          []int64
 	turn           int64
 }
 
+func NewGame(id, gameType, notaktoBoards int64) *Game {
+	size := int64(9)
+	switch gameType {
+	case proto.ConnectFour:
+		size = connectFourRows * connectFourColumns
+	case proto.Notakto:
+		size = 9 * notaktoBoards
+	case proto.Qubic:
+		size = 64
+	}
+
+	return &Game{
+		id:       id,
+		gameType: gameType,
+		p2:       -1,
+		state:    make([]int64, size),
+		turn:     1,
+		d1:       make(chan struct{}),
+		d2:       make(chan struct{}),
+		ready:    make(chan struct{}),
+	}
+}
+
 func (g Game) String() string {
 	return fmt.Sprintf(
 		"Game #%d: %d vs. %d turn: %d",
-		g.p1/2, g.p1, g.p2, g.turn,
+		g.id, g.p1, g.p2, g.turn,
 	)
 }
 
+// cell returns the field a move is placed on or -1 if the move is not possible
+func (g Game) cell(move int64) int64 {
+	if g.gameType == proto.ConnectFour {
+		if move < 0 || move >= connectFourColumns {
+			return -1
+		}
+
+		// pieces drop down to the lowest free row of the column
+		for row := int64(connectFourRows - 1); row >= 0; row-- {
+			cell := row*connectFourColumns + move
+			if g.state[cell] == 0 {
+				return cell
+			}
+		}
+		return -1
+	}
+
+	if move < 0 || move >= int64(len(g.state)) || g.state[move] != 0 {
+		return -1
+	}
+	if g.gameType == proto.Notakto && g.isBoardDead(move/9) {
+		return -1
+	}
+	return move
+}
+
+// lastMove returns the last move of the game or -1 if there is none
+func (g Game) lastMove() int64 {
+	if len(g.moves) == 0 {
+		return -1
+	}
+	return g.moves[len(g.moves)-1]
+}
+
+// legalMoves returns the moves the player whose turn it is can make, none
+// once the game is over
+func (g Game) legalMoves() []int64 {
+	if g.isOver() {
+		return nil
+	}
+
+	moves := []int64{}
+	for move := int64(0); move < int64(len(g.state)); move++ {
+		if g.cell(move) >= 0 {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
 func (g Game) IsWon(pId int64) bool {
 	var p int64
 	if g.p1 == pId {
@@ -273,19 +480,26 @@ func (g Game) IsWon(pId int64) bool {
 		p = 2
 	}
 
-	places := [][]int64{
-		{0, 1, 2},
-		{3, 4, 5},
-		{6, 7, 8},
-		{0, 4, 8},
-		{6, 4, 2},
-		{0, 3, 6},
-		{1, 4, 7},
-		{2, 5, 8},
+	switch g.gameType {
+	case proto.MisereTicTacToe:
+		// completing a line loses the game
+		return g.hasLine(3 - p)
+	case proto.Notakto:
+		// whoever kills the last board loses, so the player to move next wins
+		for b := int64(0); b < int64(len(g.state))/9; b++ {
+			if !g.isBoardDead(b) {
+				return false
+			}
+		}
+		return g.turn == p
+	default:
+		return g.hasLine(p)
 	}
+}
 
-	for _, ps := range places {
-		if p == g.state[ps[0]] && p == g.state[ps[1]] && p == g.state[ps[2]] {
+func (g Game) hasLine(p int64) bool {
+	for _, ps := range lines[g.gameType] {
+		if g.isLine(ps, 0, p) {
 			return true
 		}
 	}
@@ -293,6 +507,34 @@ func (g Game) IsWon(pId int64) bool {
 	return false
 }
 
+// isBoardDead reports whether a Notakto board contains a completed line
+func (g Game) isBoardDead(board int64) bool {
+	for _, ps := range lines[proto.RegularTicTacToe] {
+		if g.isLine(ps, board*9, 1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g Game) isLine(places []int64, offset, p int64) bool {
+	for _, place := range places {
+		if g.state[offset+place] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// seat returns 1 if the player moves first and 2 otherwise
+func (g Game) seat(pId int64) int64 {
+	if pId == g.p1 {
+		return 1
+	}
+	return 2
+}
+
 func (g Game) IsLost(p int64) bool {
 	if g.p1 == p {
 		return g.IsWon(g.p2)
@@ -308,7 +550,7 @@ func (g Game) IsDraw() bool {
 		}
 	}
 
-	return true
+	return !g.IsWon(g.p1) && !g.IsWon(g.p2)
 }
 
 func (g Game) isOver() bool {
@@ -331,8 +573,156 @@ func (g Game) WaitForPlayer2() {
 	<-g.d2
 }
 
+const connectFourRows = 6
+const connectFourColumns = 7
+
+// all places which have to be occupied by one player to win, by game type
+var lines = map[int64][][]int64{
+	proto.RegularTicTacToe: {
+		{0, 1, 2},
+		{3, 4, 5},
+		{6, 7, 8},
+		{0, 4, 8},
+		{6, 4, 2},
+		{0, 3, 6},
+		{1, 4, 7},
+		{2, 5, 8},
+	},
+	proto.ConnectFour: connectFourLines(),
+	proto.Qubic:       qubicLines(),
+}
+
+func init() {
+	lines[proto.MisereTicTacToe] = lines[proto.RegularTicTacToe]
+}
+
+func connectFourLines() [][]int64 {
+	var places [][]int64
+
+	// right, down, down right and down left
+	directions := [][2]int64{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for row := int64(0); row < connectFourRows; row++ {
+		for column := int64(0); column < connectFourColumns; column++ {
+			for _, d := range directions {
+				endRow, endColumn := row+3*d[0], column+3*d[1]
+				if endRow >= connectFourRows || endColumn < 0 || endColumn >= connectFourColumns {
+					continue
+				}
+
+				line := make([]int64, 4)
+				for i := int64(0); i < 4; i++ {
+					line[i] = (row+i*d[0])*connectFourColumns + column + i*d[1]
+				}
+				places = append(places, line)
+			}
+		}
+	}
+
+	return places
+}
+
+// qubicLines returns the 76 lines of a 4x4x4 cube, fields are numbered
+// layer*16 + row*4 + column
+func qubicLines() [][]int64 {
+	var places [][]int64
+
+	// every direction is only used once, so a line is not found twice from
+	// both of its ends
+	var directions [][3]int64
+	for dl := int64(-1); dl <= 1; dl++ {
+		for dr := int64(-1); dr <= 1; dr++ {
+			for dc := int64(-1); dc <= 1; dc++ {
+				if dl > 0 || (dl == 0 && dr > 0) || (dl == 0 && dr == 0 && dc > 0) {
+					directions = append(directions, [3]int64{dl, dr, dc})
+				}
+			}
+		}
+	}
+
+	inCube := func(v int64) bool { return v >= 0 && v < 4 }
+	for layer := int64(0); layer < 4; layer++ {
+		for row := int64(0); row < 4; row++ {
+			for column := int64(0); column < 4; column++ {
+				for _, d := range directions {
+					if !inCube(layer+3*d[0]) || !inCube(row+3*d[1]) || !inCube(column+3*d[2]) {
+						continue
+					}
+
+					line := make([]int64, 4)
+					for i := int64(0); i < 4; i++ {
+						line[i] = (layer+i*d[0])*16 + (row+i*d[1])*4 + column + i*d[2]
+					}
+					places = append(places, line)
+				}
+			}
+		}
+	}
+
+	return places
+}
+
 type Stats struct {
 	won  int64
 	lost int64
 	draw int64
 }
+
+func (st Stats) String() string {
+	return fmt.Sprintf(
+		"%.0f%% (%d / %d / %d)",
+		float64(st.won)/float64(st.won+st.draw+st.lost)*100,
+		st.won,
+		st.draw,
+		st.lost,
+	)
+}
+
+func (st *Stats) add(result int64) {
+	switch result {
+	case proto.Won:
+		st.won++
+	case proto.Lost:
+		st.lost++
+	case proto.Draw:
+		st.draw++
+	}
+}
+
+type PlayerStats struct {
+	Stats
+	seats [2]Stats // results when moving first and second
+}
+
+func (ps *PlayerStats) add(seat, result int64) {
+	ps.Stats.add(result)
+	ps.seats[seat-1].add(result)
+}
+
+// addResult counts and records a finished game, the result is from the view
+// of player 1
+func (s *Server) addResult(g *Game, result int64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.records != nil {
+		err := s.records.Write(record.Record{
+			GameType: g.gameType,
+			Players:  [2]string{g.p1Name, g.p2Name},
+			Moves:    g.moves,
+			Result:   result,
+		})
+		if err != nil {
+			log.Printf("unable to record game: %s", err)
+		}
+	}
+
+	s.stats[g.p1Name].add(1, result)
+	switch result {
+	case proto.Won:
+		s.stats[g.p2Name].add(2, proto.Lost)
+	case proto.Lost:
+		s.stats[g.p2Name].add(2, proto.Won)
+	default:
+		s.stats[g.p2Name].add(2, result)
+	}
+}
@@ -0,0 +1,393 @@
+// Package board implements the rules of ultimate tic-tac-toe, for the server
+// and for players that need to look ahead, e.g. to search or to know the legal
+// moves.
+package board
+
+import (
+	"github.com/arenaio/woodhack2018/proto"
+)
+
+// Results of a game or board
+const (
+	Unfinished int64 = 0
+	Draw       int64 = -1
+)
+
+// DefaultRules are the rules of the server without flags
+var DefaultRules = &proto.Rules{TiebreakByCount: true, Depth: 2}
+
+// Lines are the places of the fields of a board that form a line
+var Lines = [][]int64{
+	{0, 1, 2},
+	{3, 4, 5},
+	{6, 7, 8},
+	{0, 4, 8},
+	{6, 4, 2},
+	{0, 3, 6},
+	{1, 4, 7},
+	{2, 5, 8},
+}
+
+// Board is a game of (nested) tic-tac-toe between player 1 and 2
+type Board struct {
+	rules    *proto.Rules
+	state    []int64
+	results  [][]int64 // of the boards by height, see boardResult
+	turn     int64
+	lastMove int64
+}
+
+// New returns the empty board, rules of nil are the default rules
+func New(rules *proto.Rules) *Board {
+	if rules == nil || rules.Depth < 1 {
+		rules = DefaultRules
+	}
+
+	b := &Board{
+		rules:    rules,
+		state:    make([]int64, Size(rules.Depth)),
+		results:  make([][]int64, rules.Depth),
+		turn:     1,
+		lastMove: -1,
+	}
+
+	// there are 9^(depth-height) boards of each height, height 0 are the fields
+	// and height depth the whole game which is not stored here
+	for height := int64(1); height < rules.Depth; height++ {
+		b.results[height] = make([]int64, Size(rules.Depth-height))
+	}
+
+	return b
+}
+
+// FromState returns the board of a state as sent by the server, 1 are the
+// fields of the player to move who becomes player 1. The history is unknown,
+// so boards with lines of both players are given to the player of the last
+// move if it was made on them and to player 1 otherwise.
+func FromState(rules *proto.Rules, state []int64, lastMove int64) *Board {
+	b := New(rules)
+	if int64(len(state)) != int64(len(b.state)) {
+		return nil
+	}
+
+	for i, v := range state {
+		switch v {
+		case 1:
+			b.state[i] = 1
+		case -1:
+			b.state[i] = 2
+		}
+	}
+	b.lastMove = lastMove
+
+	for height := int64(1); height < b.rules.Depth; height++ {
+		for board := range b.results[height] {
+			lastPlayer := int64(1)
+			if lastMove >= 0 && lastMove/Size(height) == int64(board) {
+				lastPlayer = 2
+			}
+			b.results[height][board] = b.boardResult(height, int64(board), lastPlayer)
+		}
+	}
+
+	return b
+}
+
+// Size returns the number of fields of a board of the given height
+func Size(height int64) int64 {
+	size := int64(1)
+	for i := int64(0); i < height; i++ {
+		size *= 9
+	}
+	return size
+}
+
+// Clone returns an independent copy of the board
+func (b *Board) Clone() *Board {
+	c := &Board{
+		rules:    b.rules,
+		state:    make([]int64, len(b.state)),
+		results:  make([][]int64, len(b.results)),
+		turn:     b.turn,
+		lastMove: b.lastMove,
+	}
+	copy(c.state, b.state)
+	for height, results := range b.results {
+		if results != nil {
+			c.results[height] = make([]int64, len(results))
+			copy(c.results[height], results)
+		}
+	}
+	return c
+}
+
+func (b *Board) Rules() *proto.Rules {
+	return b.rules
+}
+
+// Size returns the number of fields of the board
+func (b *Board) Size() int64 {
+	return int64(len(b.state))
+}
+
+// Turn returns the player to move
+func (b *Board) Turn() int64 {
+	return b.turn
+}
+
+func (b *Board) LastMove() int64 {
+	return b.lastMove
+}
+
+// Field returns the player owning a field or 0 if it's empty
+func (b *Board) Field(field int64) int64 {
+	return b.state[field]
+}
+
+// Equal reports whether the fields of a state as sent by the server to the
+// player to move are the ones of the board
+func (b *Board) Equal(state []int64) bool {
+	if len(state) != len(b.state) {
+		return false
+	}
+
+	for i, v := range state {
+		switch {
+		case v == 0 && b.state[i] != 0,
+			v == 1 && b.state[i] != b.turn,
+			v == -1 && b.state[i] != 3-b.turn:
+			return false
+		}
+	}
+	return true
+}
+
+// Play makes a move for the player to move, it has to be valid
+func (b *Board) Play(move int64) {
+	b.state[move] = b.turn
+	b.turn = 3 - b.turn
+	b.lastMove = move
+	b.updateResults(move)
+}
+
+// ValidMoves returns all moves the player to move can make, none once the
+// game is over
+func (b *Board) ValidMoves() []int64 {
+	if b.Result() != Unfinished {
+		return nil
+	}
+
+	return b.AppendValidMoves(nil)
+}
+
+// AppendValidMoves appends the valid moves to a slice without checking
+// whether the game is over, which is cheaper in playouts
+func (b *Board) AppendValidMoves(moves []int64) []int64 {
+	from, to := int64(0), int64(len(b.state))
+	if board, height := b.targetBoard(); height > 0 {
+		from = board * Size(height)
+		to = from + Size(height)
+	}
+
+	for move := from; move < to; move++ {
+		if b.IsValidMove(move) {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// Result returns the winner, Draw or Unfinished
+func (b *Board) Result() int64 {
+	switch {
+	case b.IsWon(1):
+		return 1
+	case b.IsWon(2):
+		return 2
+	case b.isFinished():
+		return Draw
+	}
+	return Unfinished
+}
+
+func (b *Board) IsWon(p int64) bool {
+	depth := b.rules.Depth
+	if b.hasLine(depth, 0, p) {
+		// a drawn sub board can complete lines for both, then the last move decides
+		return !b.hasLine(depth, 0, 3-p) || b.turn != p
+	}
+
+	// counting won fields of regular tic-tac-toe makes no sense
+	if depth < 2 || b.hasLine(depth, 0, 3-p) || !b.isFinished() || !b.rules.TiebreakByCount {
+		return false
+	}
+
+	wonBoards := []int64{0, 0, 0}
+	for _, result := range b.results[depth-1] {
+		if result > 0 {
+			wonBoards[result]++
+		}
+	}
+
+	return wonBoards[p] > wonBoards[3-p]
+}
+
+// BoardResult returns the winner of a board of the given height, Draw or
+// Unfinished
+func (b *Board) BoardResult(height, board int64) int64 {
+	return b.value(height, board)
+}
+
+// CompletesLine reports whether a field completes a line of a player on its
+// sub board, other fields of the line may already be played
+func (b *Board) CompletesLine(field, p int64) bool {
+	offset, place := field/9*9, field%9
+	for _, line := range Lines {
+		if line[0] != place && line[1] != place && line[2] != place {
+			continue
+		}
+
+		count := 0
+		for _, other := range line {
+			if other != place && b.state[offset+other] == p {
+				count++
+			}
+		}
+		if count == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the field or the result of a board of the given height
+func (b *Board) value(height, board int64) int64 {
+	if height == 0 {
+		return b.state[board]
+	}
+	return b.results[height][board]
+}
+
+// hasLine checks the sub boards of a board for a line of the given player
+func (b *Board) hasLine(height, board, p int64) bool {
+	for _, places := range Lines {
+		if b.isLine(height-1, board*9, places, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLine checks if the sub boards of a line are won by the given player, drawn
+// sub boards may count for both players but can't complete a line on their own
+func (b *Board) isLine(height, offset int64, places []int64, p int64) bool {
+	won := false
+	for _, place := range places {
+		switch b.value(height, offset+place) {
+		case p:
+			won = true
+		case Draw:
+			if !b.rules.DrawCountsForBoth {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return won
+}
+
+// isFinished reports whether no more moves can be made
+func (b *Board) isFinished() bool {
+	for board := int64(0); board < 9; board++ {
+		if !b.isBoardClosed(b.rules.Depth-1, board) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBoardClosed reports whether no more moves can be made on a board, which
+// is the case if the board or one of the boards it is part of is decided
+func (b *Board) isBoardClosed(height, board int64) bool {
+	if height == 0 || b.rules.ForcedMove {
+		size := Size(height)
+		for _, v := range b.state[board*size : board*size+size] {
+			if v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	for ; height < b.rules.Depth; height, board = height+1, board/9 {
+		if b.results[height][board] != Unfinished {
+			return true
+		}
+	}
+	return false
+}
+
+// targetBoard returns the board the next move has to be made on and its
+// height, a height of 0 means that the move can be made on any board
+func (b *Board) targetBoard() (board, height int64) {
+	if b.lastMove < 0 {
+		return 0, 0
+	}
+
+	depth := b.rules.Depth
+	for height = 1; height < depth; height++ {
+		board = b.lastMove % Size(depth-1) / Size(height-1)
+		if !b.isBoardClosed(height, board) {
+			return board, height
+		}
+	}
+	return 0, 0
+}
+
+// IsValidMove checks a move against the field, the target board and closed
+// boards
+func (b *Board) IsValidMove(move int64) bool {
+	if move < 0 || move >= int64(len(b.state)) || b.state[move] != 0 {
+		return false
+	}
+
+	if board, height := b.targetBoard(); height > 0 && move/Size(height) != board {
+		return false
+	}
+
+	return b.rules.Depth < 2 || !b.isBoardClosed(1, move/9)
+}
+
+// updateResults decides the boards containing a move, once decided the result
+// of a board does not change anymore
+func (b *Board) updateResults(move int64) {
+	for height := int64(1); height < b.rules.Depth; height++ {
+		board := move / Size(height)
+		if b.results[height][board] == Unfinished {
+			b.results[height][board] = b.boardResult(height, board, b.state[move])
+		}
+	}
+}
+
+// boardResult returns the winner of a board, Unfinished or Draw
+func (b *Board) boardResult(height, board, lastPlayer int64) int64 {
+	won1 := b.hasLine(height, board, 1)
+	won2 := b.hasLine(height, board, 2)
+	switch {
+	case won1 && won2:
+		return lastPlayer
+	case won1:
+		return 1
+	case won2:
+		return 2
+	}
+
+	for i := int64(0); i < 9; i++ {
+		if !b.isBoardClosed(height-1, board*9+i) {
+			return Unfinished
+		}
+	}
+
+	return Draw
+}
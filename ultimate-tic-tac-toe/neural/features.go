@@ -0,0 +1,46 @@
+package neural
+
+import (
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
+)
+
+// Fields of ultimate tic-tac-toe, the only depth the features support
+const Fields = 81
+
+// Inputs is the number of features: own fields, opponent fields, the legal
+// moves (which marks the board the move is forced to) and the results of the
+// sub boards as won, lost or drawn
+const Inputs = 3*Fields + 3*9
+
+// Features returns the input of the network for a position from the view of
+// the player to move
+func Features(b *board.Board) []float64 {
+	input := make([]float64, Inputs)
+	me := b.Turn()
+
+	for field := int64(0); field < Fields; field++ {
+		switch b.Field(field) {
+		case me:
+			input[field] = 1
+		case 3 - me:
+			input[Fields+field] = 1
+		}
+	}
+
+	for _, move := range b.AppendValidMoves(nil) {
+		input[2*Fields+move] = 1
+	}
+
+	for sub := int64(0); sub < 9; sub++ {
+		switch b.BoardResult(1, sub) {
+		case me:
+			input[3*Fields+sub] = 1
+		case 3 - me:
+			input[3*Fields+9+sub] = 1
+		case board.Draw:
+			input[3*Fields+18+sub] = 1
+		}
+	}
+
+	return input
+}
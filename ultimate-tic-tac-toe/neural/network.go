@@ -0,0 +1,294 @@
+// Package neural is a small multilayer perceptron with a policy and a value
+// head for ultimate tic-tac-toe, written in plain Go.
+//
+// A hidden ReLU layer is shared by both heads. The policy is a softmax over
+// the legal moves and the value a tanh between -1 (lost) and 1 (won) for the
+// player to move.
+package neural
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+)
+
+const magic = "UTNN"
+
+// Version of the model format written
+const Version uint16 = 1
+
+type Network struct {
+	Inputs, Hidden, Outputs int
+
+	w1 []float64 // Hidden x Inputs
+	b1 []float64
+	wp []float64 // Outputs x Hidden
+	bp []float64
+	wv []float64 // Hidden
+	bv float64
+}
+
+// Sample is a position with the targets to train towards
+type Sample struct {
+	Input  []float64
+	Legal  []int64
+	Policy []float64 // by output, only the legal ones are used
+	Value  float64
+}
+
+// New creates a network with random weights
+func New(inputs, hidden, outputs int, r *rand.Rand) *Network {
+	n := &Network{
+		Inputs:  inputs,
+		Hidden:  hidden,
+		Outputs: outputs,
+		w1:      make([]float64, hidden*inputs),
+		b1:      make([]float64, hidden),
+		wp:      make([]float64, outputs*hidden),
+		bp:      make([]float64, outputs),
+		wv:      make([]float64, hidden),
+	}
+
+	// He initialization for the ReLU layer, small weights for the heads
+	for i := range n.w1 {
+		n.w1[i] = r.NormFloat64() * math.Sqrt(2/float64(inputs))
+	}
+	for i := range n.wp {
+		n.wp[i] = r.NormFloat64() * math.Sqrt(1/float64(hidden))
+	}
+	for i := range n.wv {
+		n.wv[i] = r.NormFloat64() * math.Sqrt(1/float64(hidden))
+	}
+
+	return n
+}
+
+// forward returns the hidden activations, the policy over the legal moves and
+// the value
+func (n *Network) forward(input []float64, legal []int64) ([]float64, []float64, float64) {
+	hidden := make([]float64, n.Hidden)
+	for h := range hidden {
+		sum := n.b1[h]
+		weights := n.w1[h*n.Inputs : (h+1)*n.Inputs]
+		for i, x := range input {
+			if x != 0 {
+				sum += weights[i] * x
+			}
+		}
+		if sum > 0 {
+			hidden[h] = sum
+		}
+	}
+
+	policy := make([]float64, n.Outputs)
+	max := -math.MaxFloat64
+	for _, move := range legal {
+		sum := n.bp[move]
+		weights := n.wp[int(move)*n.Hidden : (int(move)+1)*n.Hidden]
+		for h, a := range hidden {
+			sum += weights[h] * a
+		}
+		policy[move] = sum
+		if sum > max {
+			max = sum
+		}
+	}
+	total := 0.0
+	for _, move := range legal {
+		policy[move] = math.Exp(policy[move] - max)
+		total += policy[move]
+	}
+	for _, move := range legal {
+		policy[move] /= total
+	}
+
+	value := n.bv
+	for h, a := range hidden {
+		value += n.wv[h] * a
+	}
+
+	return hidden, policy, math.Tanh(value)
+}
+
+// Predict returns the probabilities of the legal moves and the value of a
+// position
+func (n *Network) Predict(input []float64, legal []int64) ([]float64, float64) {
+	_, policy, value := n.forward(input, legal)
+	return policy, value
+}
+
+// Train makes a gradient descent step on the cross entropy of the policy and
+// the squared error of the value averaged over a batch, it returns the loss
+func (n *Network) Train(batch []Sample, learningRate float64) float64 {
+	if len(batch) == 0 {
+		return 0
+	}
+
+	gw1 := make([]float64, len(n.w1))
+	gb1 := make([]float64, len(n.b1))
+	gwp := make([]float64, len(n.wp))
+	gbp := make([]float64, len(n.bp))
+	gwv := make([]float64, len(n.wv))
+	gbv := 0.0
+	loss := 0.0
+
+	dHidden := make([]float64, n.Hidden)
+	for _, s := range batch {
+		hidden, policy, value := n.forward(s.Input, s.Legal)
+
+		for h := range dHidden {
+			dHidden[h] = 0
+		}
+
+		for _, move := range s.Legal {
+			if s.Policy[move] > 0 {
+				loss -= s.Policy[move] * math.Log(policy[move]+1e-12)
+			}
+
+			d := policy[move] - s.Policy[move]
+			gbp[move] += d
+			offset := int(move) * n.Hidden
+			for h, a := range hidden {
+				gwp[offset+h] += d * a
+				dHidden[h] += d * n.wp[offset+h]
+			}
+		}
+
+		loss += (value - s.Value) * (value - s.Value)
+		dValue := 2 * (value - s.Value) * (1 - value*value)
+		gbv += dValue
+		for h, a := range hidden {
+			gwv[h] += dValue * a
+			dHidden[h] += dValue * n.wv[h]
+		}
+
+		for h, a := range hidden {
+			if a <= 0 {
+				continue // ReLU
+			}
+			gb1[h] += dHidden[h]
+			offset := h * n.Inputs
+			for i, x := range s.Input {
+				if x != 0 {
+					gw1[offset+i] += dHidden[h] * x
+				}
+			}
+		}
+	}
+
+	step := learningRate / float64(len(batch))
+	update := func(weights, gradients []float64) {
+		for i, g := range gradients {
+			weights[i] -= step * g
+		}
+	}
+	update(n.w1, gw1)
+	update(n.b1, gb1)
+	update(n.wp, gwp)
+	update(n.bp, gbp)
+	update(n.wv, gwv)
+	n.bv -= step * gbv
+
+	return loss / float64(len(batch))
+}
+
+// Save writes the model: the magic "UTNN", the version, the sizes as uint32
+// and all weights as float32, little endian. It is written to a temporary
+// file first which replaces the model once complete.
+func (n *Network) Save(path string) error {
+	tmp := path + ".tmp"
+	if err := n.write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (n *Network) write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if _, err := w.WriteString(magic); err != nil {
+		return err
+	}
+	header := []interface{}{Version, uint32(n.Inputs), uint32(n.Hidden), uint32(n.Outputs)}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	for _, weights := range n.weights() {
+		for _, v := range weights {
+			if err := binary.Write(w, binary.LittleEndian, float32(v)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := binary.Write(w, binary.LittleEndian, float32(n.bv)); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads a model written by Save
+func Load(path string) (*Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, m); err != nil || string(m) != magic {
+		return nil, errors.New("not a model file")
+	}
+
+	var version uint16
+	var inputs, hidden, outputs uint32
+	for _, v := range []interface{}{&version, &inputs, &hidden, &outputs} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("invalid header: %s", err)
+		}
+	}
+	if version != Version {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	n := New(int(inputs), int(hidden), int(outputs), rand.New(rand.NewSource(0)))
+	var v float32
+	for _, weights := range n.weights() {
+		for i := range weights {
+			if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+				return nil, fmt.Errorf("truncated model: %s", err)
+			}
+			weights[i] = float64(v)
+		}
+	}
+	if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+		return nil, fmt.Errorf("truncated model: %s", err)
+	}
+	n.bv = float64(v)
+
+	return n, nil
+}
+
+// weights returns the weight slices in the order of the model file, the bias
+// of the value comes last
+func (n *Network) weights() [][]float64 {
+	return [][]float64{n.w1, n.b1, n.wp, n.bp, n.wv}
+}
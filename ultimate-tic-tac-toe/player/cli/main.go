@@ -8,8 +8,8 @@ import (
 	"log"
 
 	"github.com/nsf/termbox-go"
-	"google.golang.org/grpc"
 
+	"github.com/arenaio/woodhack2018/client"
 	"github.com/arenaio/woodhack2018/proto"
 )
 
@@ -25,21 +25,23 @@ func main() {
 		log.Fatalf("unable to initialize terminal interface: %s", err)
 	}
 
-	conn, err := grpc.Dial(*address, grpc.WithInsecure())
+	runner, err := client.NewRunner(*address, *name, proto.UltimateTicTacToe)
 	if err != nil {
-		log.Fatalf("unable to connect on port %s: %v", *address, err)
+		log.Fatal(err)
 	}
-	defer conn.Close()
+	defer runner.Close()
 
-	g := NewGame(*name, *player1Char, *player2Char)
-	err = g.run(proto.NewTicTacToeClient(conn), context.Background())
-	if err != nil {
+	g := NewGame(*player1Char, *player2Char)
+	_, err = runner.Play(context.Background(), g)
+	if err != nil && err != errQuit {
 		log.Fatal(err)
 	}
 }
 
+// errQuit ends the game when the player quits
+var errQuit = errors.New("quit")
+
 type Game struct {
-	name         string
 	player1Char  string
 	player2Char  string
 	positionXOld int
@@ -48,9 +50,8 @@ type Game struct {
 	positionY    int
 }
 
-func NewGame(name, player1Char, player2Char string) *Game {
+func NewGame(player1Char, player2Char string) *Game {
 	return &Game{
-		name:         name,
 		player1Char:  player1Char,
 		player2Char:  player2Char,
 		positionX:    1,
@@ -153,22 +154,21 @@ func (g *Game) drawFinal(state []int64, message string) {
 	fmt.Println("  ", message)
 }
 
-func (g *Game) run(client proto.TicTacToeClient, ctx context.Context) error {
-	stateResult, err := client.NewGame(ctx, &proto.New{GameType: proto.UltimateTicTacToe, Name: g.name})
-	if err != nil {
-		return errors.New(fmt.Sprintf("unable join game: %s", err))
+// Move lets the player choose a field with the arrow keys and space, escape
+// quits the game
+func (g *Game) Move(t *client.Turn) (int64, error) {
+	g.drawInput(t.State)
+	if t.Result == proto.InvalidMove {
+		fmt.Printf("\033[25;0H => Invalid Move, Your turn           ")
 	}
 
-	id := stateResult.Id
-	g.drawInput(stateResult.State)
-
 	for {
 		switch ev := termbox.PollEvent(); ev.Type {
 		case termbox.EventKey:
 			switch ev.Key {
 			case termbox.KeyEsc:
 				termbox.Close()
-				return nil
+				return 0, errQuit
 			case termbox.KeyArrowUp:
 				if g.positionX > 1 {
 					g.positionX--
@@ -194,34 +194,27 @@ func (g *Game) run(client proto.TicTacToeClient, ctx context.Context) error {
 				pX, pY := g.displayPos(g.positionX, g.positionY)
 				fmt.Printf("\033[0;94m\033[%v;%vH %s \033[0m", pX, pY-2, g.player1Char)
 				fmt.Printf("\033[25;0H => Enemies turn           ")
-				moveTarget := g.getMove(g.positionX, g.positionY)
-				stateResult, err = client.Move(ctx, &proto.Action{Id: id, Move: int64(moveTarget)})
-				if err != nil {
-					log.Fatalf("an error trying to make a move: %s", err)
-				}
-				switch stateResult.Result {
-				case proto.InvalidMove:
-					g.drawInput(stateResult.State)
-					fmt.Printf("\033[25;0H => Invalid Move, Your turn           ")
-				case proto.Won:
-					termbox.Close()
-					g.drawFinal(stateResult.State, "You Won")
-					return nil
-				case proto.Lost:
-					termbox.Close()
-					g.drawFinal(stateResult.State, "You Lost")
-					return nil
-				case proto.Draw:
-					termbox.Close()
-					g.drawFinal(stateResult.State, "Game Draw")
-					return nil
-				default: // valid move
-					g.drawInput(stateResult.State)
-				}
+				return int64(g.getMove(g.positionX, g.positionY)), nil
 			}
 		case termbox.EventError:
 			termbox.Close()
-			return ev.Err
+			return 0, ev.Err
 		}
 	}
 }
+
+// Observe shows the end of the game, the other turns are drawn when the
+// player is asked for the next move
+func (g *Game) Observe(move int64, t *client.Turn) {
+	switch t.Result {
+	case proto.Won:
+		termbox.Close()
+		g.drawFinal(t.State, "You Won")
+	case proto.Lost:
+		termbox.Close()
+		g.drawFinal(t.State, "You Lost")
+	case proto.Draw:
+		termbox.Close()
+		g.drawFinal(t.State, "Game Draw")
+	}
+}
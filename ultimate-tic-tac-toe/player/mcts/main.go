@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
+)
+
+func main() {
+	address := flag.String("address", ":8000", "server address")
+	name := flag.String("name", "MCTS", "bot name")
+	opponent := flag.String("opponent", "", "only play against the bot with this name")
+	moveTime := flag.Duration("time", time.Second, "time to search per move")
+	playouts := flag.Int64("playouts", 0, "playouts per move instead of a time budget")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines running playouts")
+	exploration := flag.Float64("c", math.Sqrt2, "exploration constant of UCT")
+	reuse := flag.Bool("reuse", true, "keep the searched tree of the position after the opponent's move")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time, each searches with its own workers")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
+	flag.Parse()
+
+	runner, err := client.NewRunner(*address, *name, proto.UltimateTicTacToe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer runner.Close()
+	runner.LogGames = *logGames
+	runner.Opponent = *opponent
+	runner.LogEvery = 10
+
+	newMCTS := func() client.Agent {
+		return &MCTS{
+			moveTime:    *moveTime,
+			playouts:    *playouts,
+			workers:     *workers,
+			exploration: *exploration,
+			reuse:       *reuse,
+		}
+	}
+
+	if err := runner.RunConcurrent(context.Background(), newMCTS, 0, *concurrency); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type MCTS struct {
+	moveTime    time.Duration
+	playouts    int64 // per move, the move time is used if 0
+	workers     int
+	exploration float64
+	reuse       bool
+
+	m     sync.Mutex
+	rules *proto.Rules // of the game, taken from the first turn
+	board *board.Board // of the root
+	root  *node
+}
+
+// node of the search tree, the wins are counted for the player who made the
+// move leading to it, draws count half
+type node struct {
+	parent   *node
+	move     int64
+	player   int64
+	children []*node
+	untried  []int64
+	visits   float64
+	wins     float64
+}
+
+func newNode(parent *node, move, player int64, b *board.Board) *node {
+	return &node{
+		parent:  parent,
+		move:    move,
+		player:  player,
+		untried: b.ValidMoves(),
+	}
+}
+
+// child returns the child reached by a move or nil if it wasn't expanded
+func (n *node) child(move int64) *node {
+	for _, c := range n.children {
+		if c.move == move {
+			return c
+		}
+	}
+	return nil
+}
+
+// selectChild returns the child with the best upper confidence bound
+func (n *node) selectChild(exploration float64) *node {
+	var best *node
+	bestValue := -math.MaxFloat64
+	logVisits := math.Log(n.visits)
+	for _, c := range n.children {
+		value := c.wins/c.visits + exploration*math.Sqrt(logVisits/c.visits)
+		if value > bestValue {
+			bestValue = value
+			best = c
+		}
+	}
+	return best
+}
+
+// Move searches the position after the move of the opponent
+func (m *MCTS) Move(t *client.Turn) (int64, error) {
+	if t.Result == proto.InvalidMove {
+		return 0, fmt.Errorf("made an invalid move in state %v", t.State)
+	}
+
+	if err := m.update(t); err != nil {
+		return 0, err
+	}
+	return m.search(), nil
+}
+
+// Observe follows the own moves in the tree, every game has an MCTS of its
+// own
+func (m *MCTS) Observe(move int64, t *client.Turn) {
+	if t.Result == proto.ValidMove {
+		m.board.Play(move)
+		m.root = m.root.child(move)
+	}
+}
+
+// update follows the move of the opponent, the tree is kept if it contains
+// the new position and the board is rebuilt if it differs from the server's
+func (m *MCTS) update(t *client.Turn) error {
+	if m.rules == nil {
+		m.rules = t.Rules
+	}
+
+	if m.board != nil && t.LastMove >= 0 && m.board.IsValidMove(t.LastMove) {
+		m.board.Play(t.LastMove)
+		if m.root != nil {
+			m.root = m.root.child(t.LastMove)
+		}
+	}
+
+	if m.board == nil || !m.board.Equal(t.State) {
+		// new game, opening or the opponent swapped
+		m.board = board.FromState(m.rules, t.State, t.LastMove)
+		if m.board == nil {
+			return fmt.Errorf("state of unexpected size %d", len(t.State))
+		}
+		m.root = nil
+	}
+
+	if m.root == nil || !m.reuse {
+		m.root = newNode(nil, -1, 3-m.board.Turn(), m.board)
+	}
+	m.root.parent = nil
+	return nil
+}
+
+// search runs playouts from the root and returns the most visited move
+func (m *MCTS) search() int64 {
+	deadline := time.Now().Add(m.moveTime)
+	target := m.root.visits + float64(m.playouts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for m.iterate(r, target) && (m.playouts > 0 || time.Now().Before(deadline)) {
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	var best *node
+	for _, c := range m.root.children {
+		if best == nil || c.visits > best.visits {
+			best = c
+		}
+	}
+	if best == nil {
+		// no time for a single playout
+		moves := m.board.ValidMoves()
+		return moves[rand.Intn(len(moves))]
+	}
+	return best.move
+}
+
+// iterate runs one playout, selection, expansion and backpropagation hold
+// the lock while the playout itself runs in parallel. The visits are counted
+// before the playout so other goroutines prefer other paths meanwhile.
+func (m *MCTS) iterate(r *rand.Rand, target float64) bool {
+	m.m.Lock()
+	if m.playouts > 0 && m.root.visits >= target {
+		m.m.Unlock()
+		return false
+	}
+
+	n := m.root
+	b := m.board.Clone()
+	n.visits++
+	for len(n.untried) == 0 && len(n.children) > 0 {
+		n = n.selectChild(m.exploration)
+		b.Play(n.move)
+		n.visits++
+	}
+
+	if len(n.untried) > 0 {
+		i := r.Intn(len(n.untried))
+		move := n.untried[i]
+		n.untried[i] = n.untried[len(n.untried)-1]
+		n.untried = n.untried[:len(n.untried)-1]
+
+		player := b.Turn()
+		b.Play(move)
+		c := newNode(n, move, player, b)
+		n.children = append(n.children, c)
+		n = c
+		n.visits++
+	}
+	m.m.Unlock()
+
+	result := playout(r, b)
+
+	m.m.Lock()
+	for ; n != nil; n = n.parent {
+		switch result {
+		case n.player:
+			n.wins++
+		case board.Draw:
+			n.wins += 0.5
+		}
+	}
+	m.m.Unlock()
+
+	return true
+}
+
+// playout plays random moves until the game is decided
+func playout(r *rand.Rand, b *board.Board) int64 {
+	moves := make([]int64, 0, 81)
+	for {
+		if result := b.Result(); result != board.Unfinished {
+			return result
+		}
+
+		moves = b.AppendValidMoves(moves[:0])
+		b.Play(moves[r.Intn(len(moves))])
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/neural"
+)
+
+var r *rand.Rand
+
+func init() {
+	r = rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+func main() {
+	address := flag.String("address", ":8000", "server address")
+	name := flag.String("name", "Neural", "bot name")
+	opponent := flag.String("opponent", "", "only play against the bot with this name")
+	model := flag.String("model", "neural.model", "file to load the network from and to save it to, a new one is created if it doesn't exist")
+	hidden := flag.Int("hidden", 128, "size of the hidden layer of a new network")
+	lookahead := flag.Bool("lookahead", true, "rate moves by the value of the position after them instead of by the policy")
+	selfPlay := flag.Int("selfplay", 0, "train by playing this many games against itself instead of playing on the server")
+	learningRate := flag.Float64("learningRate", 0.01, "step size of the gradient descent")
+	batchSize := flag.Int("batch", 64, "positions per training step")
+	bufferSize := flag.Int("buffer", 20000, "number of recent positions to train on")
+	temperature := flag.Float64("temperature", 0.1, "temperature of the move probabilities in self-play, lower is greedier")
+	saveEvery := flag.Int("saveEvery", 100, "self-play games between saving the network")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time on the server")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
+	flag.Parse()
+
+	network, err := neural.Load(*model)
+	if os.IsNotExist(err) {
+		log.Printf("creating a new network, %s does not exist", *model)
+		network = neural.New(neural.Inputs, *hidden, neural.Fields, r)
+	} else if err != nil {
+		log.Fatalf("unable to load network: %s", err)
+	}
+	if network.Inputs != neural.Inputs || network.Outputs != neural.Fields {
+		log.Fatalf("network of %s does not fit the features", *model)
+	}
+
+	p := &Player{
+		network:     network,
+		lookahead:   *lookahead,
+		temperature: *temperature,
+	}
+
+	if *selfPlay > 0 {
+		p.train(*selfPlay, *learningRate, *batchSize, *bufferSize, *saveEvery, *model)
+		return
+	}
+
+	runner, err := client.NewRunner(*address, *name, proto.UltimateTicTacToe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer runner.Close()
+	runner.LogGames = *logGames
+	runner.Opponent = *opponent
+	runner.LogEvery = 100
+
+	newAgent := func() client.Agent { return &game{p: p} }
+	if err := runner.RunConcurrent(context.Background(), newAgent, 0, *concurrency); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type Player struct {
+	network     *neural.Network
+	lookahead   bool
+	temperature float64
+
+	m sync.Mutex // guards the random ties of games played concurrently
+}
+
+// game is the agent of a single game, the network is shared by all games
+type game struct {
+	p     *Player
+	rules *proto.Rules // taken from the first turn
+}
+
+// Move plays greedily
+func (g *game) Move(t *client.Turn) (int64, error) {
+	if t.Result == proto.InvalidMove {
+		return 0, fmt.Errorf("made an invalid move in state %v", t.State)
+	}
+	if g.rules == nil {
+		g.rules = t.Rules
+	}
+
+	b := board.FromState(g.rules, t.State, t.LastMove)
+	if b == nil || b.Rules().Depth != 2 {
+		return 0, errors.New("only ultimate tic-tac-toe of depth 2 is supported")
+	}
+
+	// the server knows the results of the sub boards, the state alone may not
+	// if won sub boards stay playable
+	moves := t.LegalMoves
+	if len(moves) == 0 {
+		moves = b.ValidMoves()
+	}
+	if len(moves) == 0 {
+		return 0, errors.New("no legal move left")
+	}
+	ratings := g.p.ratings(b, moves)
+
+	g.p.m.Lock()
+	defer g.p.m.Unlock()
+	return moves[argmax(ratings)], nil
+}
+
+func (g *game) Observe(move int64, t *client.Turn) {}
+
+// ratings returns how good the moves are for the player to move, either the
+// value of the position after the move (1 is won, -1 lost) or the policy
+func (p *Player) ratings(b *board.Board, moves []int64) []float64 {
+	ratings := make([]float64, len(moves))
+	if !p.lookahead {
+		policy, _ := p.network.Predict(neural.Features(b), moves)
+		for i, move := range moves {
+			ratings[i] = policy[move]
+		}
+		return ratings
+	}
+
+	me := b.Turn()
+	for i, move := range moves {
+		c := b.Clone()
+		c.Play(move)
+		switch c.Result() {
+		case me:
+			ratings[i] = 1
+		case 3 - me:
+			ratings[i] = -1
+		case board.Draw:
+			ratings[i] = 0
+		default:
+			_, value := p.network.Predict(neural.Features(c), c.AppendValidMoves(nil))
+			ratings[i] = -value // the value is for the opponent
+		}
+	}
+	return ratings
+}
+
+// train plays games against itself, the policy is trained towards the
+// probabilities of the moves derived from their ratings and the value towards
+// the result of the game
+func (p *Player) train(games int, learningRate float64, batchSize, bufferSize, saveEvery int, model string) {
+	var buffer []neural.Sample
+	loss, steps := 0.0, 0
+	results := make(map[int64]int)
+
+	for gameCount := 1; gameCount <= games; gameCount++ {
+		samples, result := p.selfPlay()
+		results[result]++
+
+		buffer = append(buffer, samples...)
+		if len(buffer) > bufferSize {
+			buffer = buffer[len(buffer)-bufferSize:]
+		}
+
+		// train on as many positions as were added
+		for i := 0; i < len(samples); i += batchSize {
+			batch := make([]neural.Sample, 0, batchSize)
+			for j := 0; j < batchSize && j < len(buffer); j++ {
+				batch = append(batch, buffer[r.Intn(len(buffer))])
+			}
+			loss += p.network.Train(batch, learningRate)
+			steps++
+		}
+
+		if gameCount%saveEvery == 0 || gameCount == games {
+			log.Printf(
+				"%d games, loss: %.4f, first / second / draw: %d / %d / %d",
+				gameCount,
+				loss/float64(steps),
+				results[1],
+				results[2],
+				results[board.Draw],
+			)
+			loss, steps = 0, 0
+
+			if err := p.network.Save(model); err != nil {
+				log.Printf("unable to save network: %s", err)
+			}
+		}
+	}
+}
+
+// selfPlay plays a game with moves sampled from their probabilities and
+// returns the positions to learn from and the result
+func (p *Player) selfPlay() ([]neural.Sample, int64) {
+	b := board.New(board.DefaultRules)
+
+	var samples []neural.Sample
+	var movers []int64
+	for b.Result() == board.Unfinished {
+		moves := b.ValidMoves()
+		probabilities := softmax(p.ratings(b, moves), p.temperature)
+
+		policy := make([]float64, neural.Fields)
+		for i, move := range moves {
+			policy[move] = probabilities[i]
+		}
+		samples = append(samples, neural.Sample{
+			Input:  neural.Features(b),
+			Legal:  moves,
+			Policy: policy,
+		})
+		movers = append(movers, b.Turn())
+
+		b.Play(moves[sample(probabilities)])
+	}
+
+	result := b.Result()
+	for i := range samples {
+		switch result {
+		case movers[i]:
+			samples[i].Value = 1
+		case 3 - movers[i]:
+			samples[i].Value = -1
+		}
+	}
+
+	return samples, result
+}
+
+func softmax(values []float64, temperature float64) []float64 {
+	max := -math.MaxFloat64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	probabilities := make([]float64, len(values))
+	total := 0.0
+	for i, v := range values {
+		probabilities[i] = math.Exp((v - max) / temperature)
+		total += probabilities[i]
+	}
+	for i := range probabilities {
+		probabilities[i] /= total
+	}
+	return probabilities
+}
+
+func sample(probabilities []float64) int {
+	x := r.Float64()
+	for i, p := range probabilities {
+		x -= p
+		if x < 0 {
+			return i
+		}
+	}
+	return len(probabilities) - 1
+}
+
+// argmax returns the index of the largest value, ties are broken at random
+func argmax(values []float64) int {
+	best := -1
+	for _, i := range r.Perm(len(values)) {
+		if best < 0 || values[i] > values[best] {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/replay"
+)
+
+// game is the agent of a single game, games played concurrently share the
+// learner and hold its lock while they use it
+type game struct {
+	q     *Qlearning
+	state []int64 // of the last move
+}
+
+// newGame starts the exploration rate of the schedule for the next episode
+func (q *Qlearning) newGame() client.Agent {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if !q.frozen {
+		q.ExplorationRate = q.schedule.Episode(q.ExplorationRate, q.Games)
+	}
+	return &game{q: q}
+}
+
+// Move penalizes the illegal moves if enabled and picks one, games of other
+// depths than 2 are refused with linear approximation
+func (g *game) Move(t *client.Turn) (int64, error) {
+	q := g.q
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	// the features of the linear approximation are those of depth 2
+	if q.Weights != nil && t.Rules != nil && t.Rules.Depth != 2 {
+		return 0, errors.New("linear approximation only supports ultimate tic-tac-toe of depth 2")
+	}
+
+	if q.penalizeInvalid && !q.frozen && len(t.LegalMoves) > 0 {
+		q.penalize(t.State, t.LegalMoves)
+	}
+
+	g.state = t.State
+	return q.makeMove(t.State, t.LegalMoves), nil
+}
+
+// Observe learns from the result of a move
+func (g *game) Observe(action int64, t *client.Turn) {
+	q := g.q
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if !q.frozen {
+		q.train(replay.Transition{
+			State:       g.state,
+			Action:      action,
+			Reward:      t.Result,
+			FutureState: t.State,
+			FutureMoves: t.LegalMoves,
+			Terminal:    t.Over(),
+		})
+	}
+
+	if t.Result != proto.InvalidMove {
+		displayState(t.State)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"github.com/arenaio/woodhack2018/replay"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
+)
+
+// drawn marks a full sub board without a winner on the meta board
+const drawn int64 = 2
+
+// numFeatures is the number of features of an action, see features
+const numFeatures = 14
+
+// features describes an action in a state of the view of the player to move
+// (1: me, -1: opponent) for the linear approximation of its value
+func features(state []int64, action int64) []float64 {
+	f := make([]float64, numFeatures)
+	f[0] = 1 // bias
+
+	sub, cell := action/9, action%9
+	after := make([]int64, len(state))
+	copy(after, state)
+	after[action] = 1
+
+	meta := metaBoard(state)
+	metaAfter := metaBoard(after)
+	local := state[sub*9 : sub*9+9]
+	localAfter := after[sub*9 : sub*9+9]
+
+	// ownership of the sub board of the move and its effect on the meta board
+	if meta[sub] == 0 && metaAfter[sub] == 1 {
+		f[1] = 1
+		if hasLine(metaAfter, 1) {
+			f[2] = 1 // wins the game
+		}
+		f[3] = float64(threats(metaAfter, 1) - threats(meta, 1))
+		f[4] = float64(threats(meta, -1) - threats(metaAfter, -1))
+	}
+
+	// two in a row threats within the sub board
+	f[5] = float64(threats(localAfter, 1) - threats(local, 1))
+	f[6] = float64(threats(local, -1) - threats(localAfter, -1))
+
+	switch cell {
+	case 4:
+		f[7] = 1
+	case 0, 2, 6, 8:
+		f[8] = 1
+	}
+
+	// freedom of the opponent on the board it's sent to
+	target := after[cell*9 : cell*9+9]
+	if metaAfter[cell] != 0 {
+		f[9] = 1 // the opponent can move anywhere
+	} else {
+		opponentThreats := threats(target, -1)
+		f[10] = float64(opponentThreats)
+		f[11] = float64(threats(target, 1))
+
+		if opponentThreats > 0 {
+			won := make([]int64, 9)
+			copy(won, metaAfter)
+			won[cell] = -1
+			if hasLine(won, -1) {
+				f[12] = 1 // the opponent could win the game there
+			}
+		}
+	}
+
+	for _, v := range metaAfter {
+		switch v {
+		case 1:
+			f[13] += 1.0 / 9
+		case -1:
+			f[13] -= 1.0 / 9
+		}
+	}
+
+	return f
+}
+
+// metaBoard returns the results of the sub boards, 1 or -1 for the winner,
+// drawn or 0 if still open
+func metaBoard(state []int64) []int64 {
+	meta := make([]int64, 9)
+	for sub := range meta {
+		local := state[sub*9 : sub*9+9]
+		switch {
+		case hasLine(local, 1):
+			meta[sub] = 1
+		case hasLine(local, -1):
+			meta[sub] = -1
+		case isFull(local):
+			meta[sub] = drawn
+		}
+	}
+	return meta
+}
+
+func hasLine(fields []int64, p int64) bool {
+	for _, line := range board.Lines {
+		if fields[line[0]] == p && fields[line[1]] == p && fields[line[2]] == p {
+			return true
+		}
+	}
+	return false
+}
+
+// threats counts the lines with two of the player's fields and an open one
+func threats(fields []int64, p int64) int {
+	count := 0
+	for _, line := range board.Lines {
+		own, open := 0, 0
+		for _, place := range line {
+			switch fields[place] {
+			case p:
+				own++
+			case 0:
+				open++
+			}
+		}
+		if own == 2 && open == 1 {
+			count++
+		}
+	}
+	return count
+}
+
+func isFull(board []int64) bool {
+	for _, v := range board {
+		if v == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// linearValue approximates the value of an action by the weighted features
+func (q *Qlearning) linearValue(state []int64, action int64) float64 {
+	value := 0.0
+	for i, x := range features(state, action) {
+		value += q.Weights[i] * x
+	}
+	return value
+}
+
+// learnLinear makes a semi-gradient TD step of the weights towards the reward
+// and the best value of the future moves and returns the TD error, there is
+// no future after the end of a game
+func (q *Qlearning) learnLinear(t replay.Transition, weight float64) float64 {
+	estimatedOptimalFuture := 0.0
+	if !t.Terminal {
+		for i, move := range t.FutureMoves {
+			if value := q.linearValue(t.FutureState, move); i == 0 || value > estimatedOptimalFuture {
+				estimatedOptimalFuture = value
+			}
+		}
+	}
+
+	f := features(t.State, t.Action)
+	value := 0.0
+	for i, x := range f {
+		value += q.Weights[i] * x
+	}
+
+	delta := float64(t.Reward) + q.DiscountFactor*estimatedOptimalFuture - value
+	for i, x := range f {
+		q.Weights[i] += q.LearningRate * weight * delta * x
+	}
+	return delta
+}
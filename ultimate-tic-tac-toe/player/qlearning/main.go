@@ -6,16 +6,26 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 
+	"github.com/arenaio/woodhack2018/client"
+	"github.com/arenaio/woodhack2018/exploration"
+	"github.com/arenaio/woodhack2018/learning"
 	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/qtable"
+	"github.com/arenaio/woodhack2018/record"
+	"github.com/arenaio/woodhack2018/replay"
+	"github.com/arenaio/woodhack2018/symmetry"
+	"github.com/arenaio/woodhack2018/telemetry"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
 )
 
 var r *rand.Rand
@@ -27,53 +37,340 @@ func init() {
 func main() {
 	address := flag.String("address", ":8000", "server address")
 	name := flag.String("name", "Q-Table", "bot name")
+	opponent := flag.String("opponent", "", "only play against the bot with this name")
+	train := flag.Bool("train", true, "learn from the games played, disable to play with the table and exploration rate as they are")
+	eval := flag.Int("eval", 0, "play this many games greedily without learning and report the results instead of training")
+	replaySize := flag.Int("replay", 0, "number of recent transitions to learn from again, 0 disables experience replay")
+	replayBatch := flag.Int("replayBatch", 8, "replayed transitions learned after every move")
+	priority := flag.Float64("priority", 0.6, "exponent of the TD errors replayed transitions are sampled by, 0 samples uniformly")
+	priorityCorrection := flag.Float64("priorityCorrection", 0.4, "how much the learning rate of replayed transitions corrects for their priority, 0 not at all, 1 fully")
+	replayRecords := flag.String("replayRecords", "", "record file of a server to fill the replay buffer with, the games of depth 2 of all players are learned from both sides")
+	paramsAddress := flag.String("params", "", "address of a parameter server to share the table with other actors, it checkpoints the table instead, not for linear approximation")
+	syncEvery := flag.Int64("syncEvery", 10, "games between pushing updates to the parameter server and pulling its values")
+	penalizeInvalid := flag.Bool("penalizeInvalid", false, "train illegal moves towards the invalid move reward, they are never played")
+	linear := flag.Bool("linear", false, "approximate action values linearly by features of the position instead of a table, checkpoints are JSON")
+	symmetric := flag.Bool("symmetric", true, "learn rotations and reflections of a position as one entry, disable for tables of raw positions")
+	file := flag.String("file", "", "checkpoint to resume from, binary or .json, parameters given as flags take precedence")
+	checkpoint := flag.String("checkpoint", "", "file to write checkpoints to, q-table.qtable or q-linear.json for linear approximation by default, the previous ones are kept as file.1, file.2, ...")
+	checkpointEvery := flag.Int64("checkpointEvery", 10000, "games between checkpoints, 0 disables them")
+	keep := flag.Int("keep", 3, "number of previous checkpoints to keep")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time, they share the table")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
+	telemetryFile := flag.String("telemetry", "", "file to append the learning curve to, CSV if it ends in .csv and JSON lines otherwise")
+	telemetryEvery := flag.Int64("telemetryEvery", 1000, "games between rows of the learning curve")
+	telemetryWindow := flag.Int("telemetryWindow", 1000, "number of recent games the rates of the learning curve are taken over")
+	strategy := flag.String("exploration", exploration.EpsilonGreedy, "exploration strategy: epsilon (random moves), softmax (by action values) or ucb (by visit counts)")
+	schedule := flag.String("schedule", exploration.PerMove, "schedule of the exploration rate: move (decay after every move), linear or exponential (by episode)")
+	explorationRate := flag.Float64("explorationRate", 1, "initial exploration rate: probability of a random move, temperature of softmax or exploration constant of ucb")
+	explorationDecay := flag.Float64("explorationDecay", 0.9999999, "factor the exploration rate is multiplied with after every move, or every episode for exponential schedules")
+	explorationEnd := flag.Float64("explorationEnd", 0, "final exploration rate of linear and exponential schedules")
+	explorationEpisodes := flag.Int64("explorationEpisodes", 1000000, "episodes of a linear schedule to reach the final exploration rate")
+	learningRate := flag.Float64("learningRate", 0.001, "weight of a new estimate of an action value")
+	discountFactor := flag.Float64("discountFactor", 1, "weight of future rewards")
 	flag.Parse()
 
-	conn, err := grpc.Dial(*address, grpc.WithInsecure())
+	q := &Qlearning{
+		Table:            make(map[string]map[int64]float64),
+		ExplorationRate:  *explorationRate,
+		ExplorationDecay: *explorationDecay,
+		LearningRate:     *learningRate,
+		DiscountFactor:   *discountFactor,
+	}
+	q.penalizeInvalid = *penalizeInvalid
+	q.frozen = !*train
+	var err error
+	q.strategy, err = exploration.NewStrategy(*strategy)
 	if err != nil {
-		log.Fatalf("unable to connect on port %s: %s", *address, err)
+		log.Fatal(err)
+	}
+	if *symmetric {
+		q.symmetries = symmetry.Nested(2)
+	}
+	if *linear {
+		q.Weights = make([]float64, numFeatures)
+
+		// the approximation diverges without discount and learns slowly with the
+		// learning rate of the table
+		q.DiscountFactor = 0.9
+		q.LearningRate = 0.01
+	}
+
+	if len(*file) > 0 {
+		log.Printf("Fetching from state file: %s", *file)
+		q.fetchFromFile(*file)
+		log.Printf("Resuming after %d games", q.Games)
 	}
-	defer conn.Close()
 
-	client := proto.NewTicTacToeClient(conn)
+	// parameters given as flags take precedence over the checkpoint and the
+	// defaults of the linear approximation
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "explorationRate":
+			q.ExplorationRate = *explorationRate
+		case "explorationDecay":
+			q.ExplorationDecay = *explorationDecay
+		case "learningRate":
+			q.LearningRate = *learningRate
+		case "discountFactor":
+			q.DiscountFactor = *discountFactor
+		}
+	})
+
+	if len(*checkpoint) == 0 {
+		*checkpoint = "q-table.qtable"
+		if q.Weights != nil {
+			*checkpoint = "q-linear.json"
+		}
+	}
+	if q.strategy.String() != exploration.UCB {
+		q.Visits = nil
+	} else if q.Visits == nil {
+		q.Visits = make(map[string]map[int64]float64)
+	}
+
+	q.schedule, err = exploration.NewSchedule(*schedule, *explorationRate, *explorationEnd, q.ExplorationDecay, *explorationEpisodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Exploring by %s, schedule: %s", q.strategy, q.schedule)
+
+	if *replaySize > 0 {
+		q.replay = replay.New(*replaySize, *priority)
+		q.replayBatch = *replayBatch
+		q.priorityCorrection = *priorityCorrection
+
+		if len(*replayRecords) > 0 {
+			games, err := q.replayRecords(*replayRecords)
+			if err != nil {
+				log.Fatalf("unable to read records: %s", err)
+			}
+			log.Printf("Replaying %d transitions of %d recorded games", q.replay.Len(), games)
+		}
+	}
+
+	runner, err := client.NewRunner(*address, *name, proto.UltimateTicTacToe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer runner.Close()
+	runner.LogGames = *logGames
+	runner.Opponent = *opponent
 	ctx := context.Background()
 
-	q := &Qlearning{
-		Table:           make(map[string]map[int64]float64),
-		ExplorationRate: 1,
-		LearningRate:    0.001,
-		DiscountFactor:  1,
+	if len(*paramsAddress) > 0 {
+		if q.Weights != nil {
+			log.Fatal("the parameter server does not work with linear approximation")
+		}
+
+		q.params, err = learning.DialParams(*paramsAddress)
+		if err != nil {
+			log.Fatalf("unable to connect to the parameter server on %s: %s", *paramsAddress, err)
+		}
+		defer q.params.Close()
+
+		if err := q.params.Sync(ctx, q.Table, q.header()); err != nil {
+			log.Fatalf("unable to pull the table: %s", err)
+		}
+		log.Printf("Pulled %d states from the parameter server", len(q.Table))
 	}
 
-	for gameCount := 1; ; gameCount++ {
-		q.runGameOnServer(client, ctx, *name)
+	if *eval > 0 {
+		q.frozen = true
+		q.ExplorationRate = 0
+		if err := learning.Evaluate(ctx, runner, q.newGame, *eval, *concurrency); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-		if gameCount%1000 == 0 {
-			log.Printf("%d Episodes - Exploration Rate: %.4f", gameCount, q.ExplorationRate)
+	if len(*telemetryFile) > 0 && *telemetryEvery > 0 {
+		q.telemetry, err = telemetry.New(*telemetryFile, *telemetryWindow)
+		if err != nil {
+			log.Fatalf("unable to open %s: %s", *telemetryFile, err)
 		}
-		if gameCount%10000 == 0 {
-			q.storeTable(gameCount)
-			log.Printf("%d.json saved", gameCount)
+		defer q.telemetry.Close()
+	}
+
+	// games are counted on from the loaded table, which keeps its count if frozen
+	results := make(map[int64]int)
+	games := q.Games
+	runner.OnGame = func(g client.Game) {
+		q.m.Lock()
+		defer q.m.Unlock()
+
+		results[g.Result]++
+		games++
+		if !q.frozen {
+			q.Games = games
+		}
+
+		if q.telemetry != nil {
+			q.telemetry.Episode(g.Result, g.Moves, g.InvalidMoves)
+			if games%*telemetryEvery == 0 {
+				size := len(q.Table)
+				if q.Weights != nil {
+					size = len(q.Weights)
+				}
+				if err := q.telemetry.Write(games, q.ExplorationRate, size); err != nil {
+					log.Fatalf("unable to write to %s: %s", *telemetryFile, err)
+				}
+			}
+		}
+
+		if games%1000 == 0 {
+			log.Printf(
+				"%d Episodes - Exploration Rate (%s): %.4f - won / draw / lost: %d / %d / %d",
+				games,
+				q.strategy,
+				q.ExplorationRate,
+				results[proto.Won],
+				results[proto.Draw],
+				results[proto.Lost],
+			)
+			results = make(map[int64]int)
+		}
+
+		// a frozen table has nothing to share or save
+		if q.frozen {
+			return
+		}
+		if q.params != nil {
+			q.params.Game()
+			if q.Games%*syncEvery == 0 {
+				if err := q.params.Sync(ctx, q.Table, q.header()); err != nil {
+					log.Fatalf("unable to sync with the parameter server: %s", err)
+				}
+			}
+		} else if *checkpointEvery > 0 && q.Games%*checkpointEvery == 0 {
+			q.storeTable(*checkpoint, *keep)
+			log.Printf("%s saved after %d games", *checkpoint, q.Games)
 		}
 	}
+
+	if err := runner.RunConcurrent(ctx, q.newGame, 0, *concurrency); err != nil {
+		log.Fatal(err)
+	}
 }
 
 type Qlearning struct {
-	Table           map[string]map[int64]float64 `json:"Table"`
-	ExplorationRate float64                      `json:"ExplorationRate"`
-	LearningRate    float64                      `json:"LearningRate"`
-	DiscountFactor  float64                      `json:"DiscountFactor"`
+	Table            map[string]map[int64]float64 `json:"Table"`
+	Games            int64                        `json:"Games,omitempty"`
+	ExplorationRate  float64                      `json:"ExplorationRate"`
+	ExplorationDecay float64                      `json:"ExplorationDecay,omitempty"`
+	LearningRate     float64                      `json:"LearningRate"`
+	DiscountFactor   float64                      `json:"DiscountFactor"`
+	Weights          []float64                    `json:"Weights,omitempty"` // of the features if approximated linearly
+	Visits           map[string]map[int64]float64 `json:"Visits,omitempty"`  // of the actions for UCB exploration
+
+	m sync.Mutex // guards the learner against games played concurrently
+
+	symmetries      [][]int64 // positions are keyed by their canonical form if set
+	strategy        exploration.Strategy
+	schedule        exploration.Schedule
+	penalizeInvalid bool
+	frozen          bool // the table is not updated while evaluating or without -train
+
+	params             *learning.Params // nil without a parameter server
+	replay             *replay.Buffer   // nil without experience replay
+	replayBatch        int
+	priorityCorrection float64
+
+	telemetry *telemetry.Recorder // nil without a learning curve
+}
+
+// header returns the parameters stored with the table
+func (q *Qlearning) header() qtable.Header {
+	return qtable.Header{
+		Fields:           81,
+		Games:            q.Games,
+		ExplorationRate:  q.ExplorationRate,
+		ExplorationDecay: q.ExplorationDecay,
+		LearningRate:     q.LearningRate,
+		DiscountFactor:   q.DiscountFactor,
+	}
+}
+
+func (q *Qlearning) storeTable(path string, keep int) {
+	if q.Weights != nil {
+		q.storeWeights(path, keep)
+		return
+	}
+
+	h := q.header()
+	err := qtable.Checkpoint(path, keep, h, q.Table)
+	if err == nil && q.Visits != nil {
+		err = qtable.Checkpoint(visitsPath(path), keep, h, q.Visits)
+	}
+	if err != nil {
+		log.Printf("Error storing table after %d games: %s", q.Games, err)
+	}
+}
+
+// visitsPath returns the file of the visit counts next to the Q-table,
+// q-table.qtable becomes q-table.visits.qtable
+func visitsPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".visits" + ext
 }
 
-func (q *Qlearning) storeTable(gameCount int) {
+// storeWeights writes the linear approximation as JSON, atomically like the
+// table checkpoints
+func (q *Qlearning) storeWeights(path string, keep int) {
 	bytes, _ := json.Marshal(q)
 
-	err := ioutil.WriteFile(fmt.Sprintf("./%d.json", gameCount), bytes, 0644)
+	tmp := path + ".tmp"
+	err := ioutil.WriteFile(tmp, bytes, 0644)
+	if err == nil {
+		err = qtable.Rotate(path, keep)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
 	if err != nil {
-		log.Printf("Error storing table %d: %s", gameCount, err)
+		log.Printf("Error storing weights after %d games: %s", q.Games, err)
 	}
 }
 
+// fetchFromFile loads a binary table or a JSON one if the file ends in .json,
+// the exploration decay is kept if the file does not contain it. The visit
+// counts of UCB are loaded from their own file if it exists.
+func (q *Qlearning) fetchFromFile(filePath string) {
+	if !strings.HasSuffix(filePath, ".json") {
+		h, table, err := qtable.Load(filePath)
+		if err != nil {
+			log.Printf("Error loading state file: %s, %s", filePath, err)
+			os.Exit(1)
+		}
+
+		q.Table = table
+		q.Games = h.Games
+		q.ExplorationRate = h.ExplorationRate
+		q.LearningRate = h.LearningRate
+		q.DiscountFactor = h.DiscountFactor
+		if h.ExplorationDecay > 0 {
+			q.ExplorationDecay = h.ExplorationDecay
+		}
+
+		if q.strategy.String() == exploration.UCB {
+			_, visits, err := qtable.Load(visitsPath(filePath))
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("Error loading state file: %s, %s", visitsPath(filePath), err)
+				os.Exit(1)
+			}
+			q.Visits = visits
+		}
+		return
+	}
+
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Printf("Error loading state file: %s, %s", filePath, err)
+		os.Exit(1)
+	}
+
+	json.Unmarshal(raw, &q)
+}
+
 func hashState(state []int64) string {
 	stateStr := make([]string, len(state))
 	for i, v := range state {
@@ -82,78 +379,172 @@ func hashState(state []int64) string {
 	return strings.Join(stateStr, "")
 }
 
-func (q *Qlearning) train(lastState []int64, action int64, futureState []int64, reward int64) {
-	actionTable := q.getActionTable(lastState)
-	futureActionTable := q.getActionTable(futureState)
+// train learns from a transition, replays a batch of stored ones if enabled
+// and updates the exploration rate
+func (q *Qlearning) train(t replay.Transition) {
+	delta := q.learn(t, 1)
+	if q.telemetry != nil {
+		q.telemetry.TDError(delta)
+	}
 
-	estimatedOptimalFuture := float64(proto.InvalidMove)
-	for _, qvalue := range futureActionTable {
-		if qvalue > estimatedOptimalFuture {
-			estimatedOptimalFuture = qvalue
-		}
+	if q.replay != nil {
+		q.replay.Add(t)
+		q.learnReplayed()
 	}
 
-	learnedValue := float64(reward) + q.DiscountFactor*estimatedOptimalFuture
-	actionTable[action] = (1-q.LearningRate)*actionTable[action] + q.LearningRate*learnedValue
+	q.ExplorationRate = q.schedule.Move(q.ExplorationRate)
+}
 
-	q.ExplorationRate *= 0.9999999
+// learnReplayed learns a batch of stored transitions again and updates their
+// priorities
+func (q *Qlearning) learnReplayed() {
+	indices, weights := q.replay.Sample(r, q.replayBatch, q.priorityCorrection)
+	for k, i := range indices {
+		delta := q.learn(q.replay.Get(i), weights[k])
+		q.replay.Update(i, delta)
+		if q.telemetry != nil {
+			q.telemetry.TDError(delta)
+		}
+	}
 }
 
-func (q *Qlearning) runGameOnServer(client proto.TicTacToeClient, ctx context.Context, name string) {
-	//log.Print("Starting new game")
-	stateResult, err := client.NewGame(ctx, &proto.New{GameType: proto.UltimateTicTacToe, Name: name})
-	if err != nil {
-		log.Fatal(err)
+// learn updates the value of an action and returns the TD error, the best of
+// the future moves is estimated from all actions if they are unknown and
+// there is no future after the end of a game. The weight scales the learning
+// rate for replayed transitions.
+func (q *Qlearning) learn(t replay.Transition, weight float64) float64 {
+	if q.Weights != nil {
+		return q.learnLinear(t, weight)
 	}
 
-	displayState(stateResult.State)
+	hash, transform := q.key(t.State)
+	actionTable := q.entry(hash, len(t.State))
+	action := symmetry.Apply(transform, t.Action)
 
-	id := stateResult.Id
-	ongoingGame := true
+	learnedValue := float64(t.Reward)
+	if !t.Terminal {
+		futureActionTable, futureTransform := q.getActionTable(t.FutureState)
 
-	for ongoingGame {
-		action := q.makeMove(stateResult.State)
-		//print("\nMoving to: ", action, "\n")
+		estimatedOptimalFuture := float64(proto.InvalidMove)
+		for _, move := range t.FutureMoves {
+			if qvalue := futureActionTable[symmetry.Apply(futureTransform, move)]; qvalue > estimatedOptimalFuture {
+				estimatedOptimalFuture = qvalue
+			}
+		}
+		if len(t.FutureMoves) == 0 {
+			for _, qvalue := range futureActionTable {
+				if qvalue > estimatedOptimalFuture {
+					estimatedOptimalFuture = qvalue
+				}
+			}
+		}
 
-		lastState := stateResult.State
+		learnedValue += q.DiscountFactor * estimatedOptimalFuture
+	}
 
-		stateResult, err = client.Move(ctx, &proto.Action{Id: id, Move: action})
+	step := q.LearningRate * weight
+	delta := learnedValue - actionTable[action]
+	actionTable[action] += step * delta
 
-		if err != nil {
-			displayState(lastState)
-			log.Fatal(err)
+	if q.params != nil {
+		q.params.Add(hash, action, learnedValue, step)
+	}
+	return delta
+}
+
+// replayRecords adds the ultimate tic-tac-toe games of depth 2 of a record
+// file to the replay buffer from the view of both players and returns their
+// number
+func (q *Qlearning) replayRecords(path string) (int, error) {
+	games := 0
+	err := record.Read(path, func(rec record.Record) error {
+		if rec.GameType != proto.UltimateTicTacToe || (rec.Rules != nil && rec.Rules.Depth != 2) {
+			return nil
+		}
+
+		b := board.New(rec.Rules)
+		for _, move := range rec.Opening {
+			b.Play(move)
+		}
+		firstSeat := b.Turn()
+
+		plies := make([]replay.Ply, len(rec.Moves))
+		for i, move := range rec.Moves {
+			plies[i] = replay.Ply{State: view(b), LegalMoves: b.ValidMoves(), Move: move}
+			b.Play(move)
 		}
 
-		q.train(lastState, action, stateResult.State, stateResult.Result)
+		for _, t := range replay.Episodes(plies, firstSeat, rec.Result) {
+			q.replay.Add(t)
+		}
+		games++
+		return nil
+	})
+	return games, err
+}
 
-		switch stateResult.Result {
-		case proto.InvalidMove:
-			//print("Made an illegal move\n")
-		case proto.Won:
-			//print("Won the game!\n")
-			ongoingGame = false
-		case proto.Lost:
-			//print("Lost the game!\n")
-			ongoingGame = false
-		case proto.Draw:
-			//print("Draw game!\n")
-			ongoingGame = false
-		default:
-			// valid move
-			displayState(stateResult.State)
+// view returns the fields of a board from the view of the player to move as
+// sent by the server
+func view(b *board.Board) []int64 {
+	state := make([]int64, board.Size(b.Rules().Depth))
+	for field := range state {
+		switch b.Field(int64(field)) {
+		case b.Turn():
+			state[field] = 1
+		case 3 - b.Turn():
+			state[field] = -1
 		}
 	}
+	return state
+}
 
-	displayState(stateResult.State)
+// penalize trains the moves that are not legal in a state towards the
+// invalid move reward as if they were rejected by the server, the features of
+// the linear approximation only describe legal moves
+func (q *Qlearning) penalize(state, legalMoves []int64) {
+	if q.Weights != nil {
+		return
+	}
+
+	legal := make(map[int64]bool, len(legalMoves))
+	for _, move := range legalMoves {
+		legal[move] = true
+	}
+
+	for move := int64(0); move < int64(len(state)); move++ {
+		if !legal[move] {
+			q.learn(replay.Transition{
+				State:       state,
+				Action:      move,
+				Reward:      proto.InvalidMove,
+				FutureState: state,
+				FutureMoves: legalMoves,
+			}, 1)
+		}
+	}
 }
 
-func (q *Qlearning) getActionTable(state []int64) map[int64]float64 {
-	hash := hashState(state)
+// getActionTable returns the action values of the canonical form of a state
+// and the transformation of actions to it
+func (q *Qlearning) getActionTable(state []int64) (map[int64]float64, []int64) {
+	hash, transform := q.key(state)
+	return q.entry(hash, len(state)), transform
+}
 
+// key returns the key of the canonical form of a state and the
+// transformation of actions to it
+func (q *Qlearning) key(state []int64) (string, []int64) {
+	canonical, transform := symmetry.Canonical(state, q.symmetries)
+	return hashState(canonical), transform
+}
+
+// entry returns the action values stored under a key, they are added if the
+// state is new
+func (q *Qlearning) entry(hash string, fields int) map[int64]float64 {
 	actionTable, found := q.Table[hash]
 	if !found {
 		actionTable = make(map[int64]float64)
-		for i := 0; i < len(state); i++ {
+		for i := 0; i < fields; i++ {
 			actionTable[int64(i)] = 0
 		}
 		q.Table[hash] = actionTable
@@ -162,25 +553,58 @@ func (q *Qlearning) getActionTable(state []int64) map[int64]float64 {
 	return actionTable
 }
 
-func (q *Qlearning) makeMove(state []int64) int64 {
-	actionTable := q.getActionTable(state)
+// actionValues returns the estimate of the value of the actions of a state
+func (q *Qlearning) actionValues(state []int64) func(int64) float64 {
+	if q.Weights != nil {
+		return func(action int64) float64 {
+			return q.linearValue(state, action)
+		}
+	}
+
+	actionTable, transform := q.getActionTable(state)
+	return func(action int64) float64 {
+		return actionTable[symmetry.Apply(transform, action)]
+	}
+}
 
-	if r.Float64() < q.ExplorationRate {
-		//log.Printf("Exploring (%.2f)", q.ExplorationRate)
-		return int64(r.Intn(len(state)))
+// makeMove picks one of the legal moves by the exploration strategy, all
+// fields are tried if the server does not provide them
+func (q *Qlearning) makeMove(state, legalMoves []int64) int64 {
+	moves := legalMoves
+	if len(moves) == 0 {
+		moves = make([]int64, len(state))
+		for i := range moves {
+			moves[i] = int64(i)
+		}
 	}
 
-	//log.Printf("I know what's best... (%.2f)", q.ExplorationRate)
-	bestMove := int64(-1)
-	bestValue := -math.MaxFloat64
-	for action, value := range actionTable {
-		if value > bestValue {
-			bestValue = value
-			bestMove = action
+	probabilities := q.strategy.Probabilities(q.ExplorationRate, moves, q.actionValues(state), q.visits(state))
+	move := exploration.Sample(r, moves, probabilities)
+
+	if q.Visits != nil && !q.frozen {
+		canonical, transform := symmetry.Canonical(state, q.symmetries)
+		hash := hashState(canonical)
+		if q.Visits[hash] == nil {
+			q.Visits[hash] = make(map[int64]float64)
 		}
+		q.Visits[hash][symmetry.Apply(transform, move)]++
 	}
 
-	return bestMove
+	return move
+}
+
+// visits returns how often the actions of a state were played, they are only
+// counted for UCB exploration
+func (q *Qlearning) visits(state []int64) func(int64) float64 {
+	if q.Visits == nil {
+		return func(int64) float64 { return 0 }
+	}
+
+	canonical, transform := symmetry.Canonical(state, q.symmetries)
+	counts := q.Visits[hashState(canonical)]
+	return func(action int64) float64 {
+		return counts[symmetry.Apply(transform, action)]
+	}
 }
 
 func displayState(state []int64) {
@@ -1,69 +1,163 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"math/rand"
+	"sync"
 
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 
+	"github.com/arenaio/woodhack2018/client"
 	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
 )
 
 var r *rand.Rand
+var m sync.Mutex // guards r against games played concurrently
 
 func init() {
 	r = rand.New(rand.NewSource(199))
 }
 
+// Strategies by type, every one adds a heuristic to the previous ones and
+// picks randomly among the moves left
+const (
+	fullyRandom = iota + 1 // any field, the server rejects the invalid ones
+	legalOnly              // only legal moves, respecting the forced sub board
+	winBoard               // moves winning a sub board if there are any
+	blockBoard             // moves taking the field the opponent would win a sub board with
+	avoidBoard             // moves not sending the opponent to a sub board it can win
+)
+
 func main() {
 	address := flag.String("address", ":8000", "server address")
-	name := flag.String("name", "Random", "bot name")
+	name := flag.String("name", "Random", "bot name, Random followed by the type if only the type is set")
+	strategy := flag.Int("type", fullyRandom, "strategy: 1 fully random, 2 legal moves only, 3 win sub boards, 4 also block sub boards of the opponent, 5 also avoid sending the opponent to sub boards it can win")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time")
+	logGames := flag.Bool("logGames", false, "log the result of every game")
 	flag.Parse()
 
-	conn, err := grpc.Dial(*address, grpc.WithInsecure())
-	if err != nil {
-		log.Fatalf("unable to connect on port %s: %s", *address, err)
+	if *strategy < fullyRandom || *strategy > avoidBoard {
+		log.Fatalf("unknown type %d", *strategy)
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if set["type"] && !set["name"] {
+		*name = fmt.Sprintf("Random%d", *strategy)
 	}
-	defer conn.Close()
 
-	client := proto.NewTicTacToeClient(conn)
-	ctx := context.Background()
+	runner, err := client.NewRunner(*address, *name, proto.UltimateTicTacToe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer runner.Close()
+	runner.LogGames = *logGames
 
-	for {
-		runGameOnServer(client, ctx, *name)
+	newAgent := func() client.Agent { return &Random{strategy: *strategy} }
+	if err := runner.RunConcurrent(context.Background(), newAgent, 0, *concurrency); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func runGameOnServer(client proto.TicTacToeClient, ctx context.Context, name string) {
-	stateResult, err := client.NewGame(ctx, &proto.New{GameType: proto.UltimateTicTacToe, Name: name})
-	if err != nil {
-		log.Fatal(err)
+// Random picks moves by its strategy, every game has one of its own
+type Random struct {
+	strategy int
+	rules    *proto.Rules // of the game, taken from the first turn
+}
+
+func (s *Random) Move(t *client.Turn) (int64, error) {
+	if s.strategy == fullyRandom {
+		m.Lock()
+		defer m.Unlock()
+		return int64(r.Intn(len(t.State))), nil
+	}
+
+	if s.rules == nil {
+		s.rules = t.Rules
+	}
+	b := board.FromState(s.rules, t.State, t.LastMove)
+	if b == nil {
+		return 0, fmt.Errorf("state of unexpected size %d", len(t.State))
 	}
 
-	id := stateResult.Id
-	ongoingGame := true
+	// the server knows the results of the sub boards, the state alone may not
+	// if won sub boards stay playable
+	moves := t.LegalMoves
+	if len(moves) == 0 {
+		moves = b.ValidMoves()
+	}
+	if len(moves) == 0 {
+		return 0, errors.New("no legal move left")
+	}
 
-	for ongoingGame {
-		stateResult, err = client.Move(ctx, &proto.Action{
-			Id:   id,
-			Move: int64(r.Intn(len(stateResult.State))),
-		})
-		if err != nil {
-			log.Fatal(err)
+	// there are no sub boards in regular tic-tac-toe
+	if b.Rules().Depth >= 2 {
+		if s.strategy >= winBoard {
+			moves = prefer(moves, func(move int64) bool {
+				return winsBoard(b, move)
+			})
 		}
+		if s.strategy >= blockBoard {
+			moves = prefer(moves, func(move int64) bool {
+				return b.BoardResult(1, move/9) == board.Unfinished && b.CompletesLine(move, 3-b.Turn())
+			})
+		}
+		if s.strategy >= avoidBoard {
+			moves = prefer(moves, func(move int64) bool {
+				return !givesBoard(b, move)
+			})
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	return moves[r.Intn(len(moves))], nil
+}
+
+func (*Random) Observe(move int64, t *client.Turn) {}
+
+// prefer returns the moves matching a heuristic or all moves if none does
+func prefer(moves []int64, heuristic func(int64) bool) []int64 {
+	var preferred []int64
+	for _, move := range moves {
+		if heuristic(move) {
+			preferred = append(preferred, move)
+		}
+	}
+
+	if len(preferred) == 0 {
+		return moves
+	}
+	return preferred
+}
+
+// winsBoard reports whether a move wins the sub board it is made on for the
+// player to move
+func winsBoard(b *board.Board, move int64) bool {
+	player := b.Turn()
+	c := b.Clone()
+	c.Play(move)
+	return c.BoardResult(1, move/9) == player
+}
+
+// givesBoard reports whether the opponent can win a sub board after a move,
+// on the sub board it is sent to or anywhere if that one is closed
+func givesBoard(b *board.Board, move int64) bool {
+	c := b.Clone()
+	c.Play(move)
+	if c.Result() != board.Unfinished {
+		return false
+	}
 
-		switch stateResult.Result {
-		case proto.Won:
-			ongoingGame = false
-		case proto.Lost:
-			ongoingGame = false
-		case proto.Draw:
-			ongoingGame = false
-		case proto.InvalidMove:
-		default:
-			// valid move
+	for _, reply := range c.ValidMoves() {
+		if winsBoard(c, reply) {
+			return true
 		}
 	}
+	return false
 }
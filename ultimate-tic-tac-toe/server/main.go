@@ -4,43 +4,122 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
 	"github.com/arenaio/woodhack2018/proto"
+	"github.com/arenaio/woodhack2018/record"
+	"github.com/arenaio/woodhack2018/seats"
+	"github.com/arenaio/woodhack2018/ultimate-tic-tac-toe/board"
 )
 
+var r *rand.Rand
+
+func init() {
+	r = rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
 func main() {
 	address := flag.String("address", ":8000", "server address")
+	tiebreakByCount := flag.Bool("tiebreakByCount", true, "if no line is completed, the player with more won sub boards wins")
+	forcedMove := flag.Bool("forcedMove", false, "won sub boards stay playable until they are full, so being sent to a won sub board is no free move")
+	drawCountsForBoth := flag.Bool("drawCountsForBoth", false, "a drawn sub board counts for both players when completing a line")
+	depth := flag.Int64("depth", 2, "levels of nested boards, 1 is regular and 2 ultimate tic-tac-toe")
+	pieRule := flag.Bool("pieRule", false, "the second player may take over the position after the first move instead of moving, not used with openings")
+	seatPolicy := flag.String("seats", seats.Join, "who moves first: join, random, alternate or balanced")
+	openingFile := flag.String("openings", "", "file with one opening per line to start games from, every opening is played twice with reversed seats")
+	openingMoves := flag.Int("openingMoves", 0, "number of random moves to start games from if no opening file is given")
+	recordFile := flag.String("records", "", "file to append the records of finished games to")
 	flag.Parse()
 
+	seatAssigner, err := seats.NewAssigner(*seatPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *depth < 1 {
+		log.Fatalf("invalid depth %d, it has to be at least 1", *depth)
+	}
+
+	rules := &proto.Rules{
+		TiebreakByCount:   *tiebreakByCount,
+		ForcedMove:        *forcedMove,
+		DrawCountsForBoth: *drawCountsForBoth,
+		Depth:             *depth,
+		PieRule:           *pieRule,
+	}
+
+	var openings *Openings
+	if len(*openingFile) > 0 {
+		openings, err = LoadOpenings(*openingFile, rules)
+		if err != nil {
+			log.Fatalf("unable to load openings: %s", err)
+		}
+		log.Printf("loaded %d openings from %s", len(openings.book), *openingFile)
+	} else if *openingMoves > 0 {
+		// an opening has to leave at least one move to play
+		if int64(*openingMoves) >= board.Size(*depth) {
+			log.Fatalf("invalid number of opening moves %d, a board of depth %d has %d fields", *openingMoves, *depth, board.Size(*depth))
+		}
+		openings = &Openings{moves: *openingMoves, rules: rules}
+		if _, err := openings.next(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var records *record.Writer
+	if len(*recordFile) > 0 {
+		records, err = record.Create(*recordFile)
+		if err != nil {
+			log.Fatalf("unable to open record file: %s", err)
+		}
+		defer records.Close()
+	}
+
 	listener, err := net.Listen("tcp", *address)
 	if err != nil {
 		log.Fatalf("unable to listen on port %s: %v", *address, err)
 	}
 
 	srv := grpc.NewServer()
-	proto.RegisterTicTacToeServer(srv, NewServer())
-	log.Printf("listening on %s", *address)
+	proto.RegisterTicTacToeServer(srv, NewServer(rules, seatAssigner, openings, records))
+	log.Printf("listening on %s with rules: %s", *address, rules)
 	log.Print(srv.Serve(listener))
 }
 
 type Server struct {
-	games        map[int64]*Game
+	games        map[int64]*Game // by player id
+	waiting      []*Game         // games waiting for a second player, oldest first
 	m            sync.Mutex
 	nextPlayerId int64
-	stats        map[string]*Stats
+	nextGameId   int64
+	stats        map[string]*PlayerStats
+	seats        *seats.Assigner
+	rules        *proto.Rules
+	openings     *Openings // nil if games start from an empty board
+	rematches    *seats.Rematches
+	records      *record.Writer
 }
 
-func NewServer() *Server {
+func NewServer(rules *proto.Rules, seatAssigner *seats.Assigner, openings *Openings, records *record.Writer) *Server {
 	return &Server{
-		games: make(map[int64]*Game),
-		stats: make(map[string]*Stats),
+		games:     make(map[int64]*Game),
+		stats:     make(map[string]*PlayerStats),
+		seats:     seatAssigner,
+		rules:     rules,
+		openings:  openings,
+		rematches: seats.NewRematches(),
+		records:   records,
 	}
 }
 
@@ -53,15 +132,14 @@ func (s *Server) String() string {
 }
 
 func (s *Server) getStats() string {
-	stats := []string{"Current Standing"}
+	stats := []string{"Current Standing (won / draw / lost)"}
 	for name, stat := range s.stats {
 		stats = append(stats, fmt.Sprintf(
-			"%s\t%.0f%% (%d / %d / %d)",
+			"%s\t%s\tfirst: %s\tsecond: %s",
 			name,
-			float64(stat.won)/float64(stat.won+stat.draw+stat.lost)*100,
-			stat.won,
-			stat.draw,
-			stat.lost,
+			stat.Stats,
+			stat.seats[0],
+			stat.seats[1],
 		))
 	}
 
@@ -74,145 +152,172 @@ func (s *Server) NewGame(ctx context.Context, new *proto.New) (*proto.StateResul
 	s.m.Lock()
 	_, found := s.stats[new.Name]
 	if !found {
-		s.stats[new.Name] = &Stats{}
+		s.stats[new.Name] = &PlayerStats{}
 	}
 
 	playerId := s.nextPlayerId
 
-	var g *Game
-	var ok bool
-
 	if playerId%1000 == 1 {
 		log.Print(s.getStats())
 	}
 
-	if playerId%2 == 0 {
-		g = &Game{
-			p1:       playerId,
-			p1Name:   new.Name,
-			p2:       -1,
-			state:    make([]int64, 81),
-			turn:     1,
-			d1:       make(chan struct{}),
-			d2:       make(chan struct{}),
-			lastMove: -1,
-		}
-		s.games[playerId/2] = g
+	g := s.findGame(new)
+	if g == nil {
+		g = NewGame(s.rules)
+		g.id = s.nextGameId
+		g.p1 = playerId
+		g.p1Name = new.Name
+		g.opponent = new.Opponent
+		s.waiting = append(s.waiting, g)
+		s.nextGameId++
 		//log.Printf("new game created: %s", g)
 	} else {
-		//log.Printf("trying to join game: %d", (playerId-1)/2)
-		g, ok = s.games[(playerId-1)/2]
-		if !ok {
-			return nil, errors.New("game not found")
-		}
 		g.p2 = playerId
 		g.p2Name = new.Name
+		s.prepareGame(g)
+		close(g.ready)
 		//log.Printf("found game: %s", g)
 	}
+	s.games[playerId] = g
 
 	s.nextPlayerId++
 	s.m.Unlock()
 
-	if playerId%2 != 0 {
-		g.WaitForPlayer1() // since player 1 begins the game wait for first move
+	<-g.ready // the seats are assigned once the second player joined
+	if seat := g.seat(playerId); seat != g.firstTurn() {
+		// wait for the first move of the other player
+		if seat == 1 {
+			g.WaitForPlayer2()
+		} else {
+			g.WaitForPlayer1()
+		}
 	}
 
 	return &proto.StateResult{
-		Id:     playerId,
-		State:  mapOutput(g.state, playerId == g.p1),
-		Result: proto.ValidMove,
+		Id:         playerId,
+		Seat:       g.seat(playerId),
+		State:      g.state(playerId),
+		Result:     proto.ValidMove,
+		LastMove:   g.board.LastMove(),
+		Rules:      g.board.Rules(),
+		LegalMoves: g.board.ValidMoves(),
 	}, nil
 }
 
+// findGame removes and returns the oldest waiting game the new player can
+// join, both players can ask for an opponent by name. Bots of the same name
+// only play each other if one of them asks for itself as the opponent.
+func (s *Server) findGame(new *proto.New) *Game {
+	for i, g := range s.waiting {
+		if (len(g.opponent) > 0 && g.opponent != new.Name) || (len(new.Opponent) > 0 && new.Opponent != g.p1Name) {
+			continue
+		}
+		// games of a bot playing concurrently would be paired with each other
+		if len(new.Name) > 0 && g.p1Name == new.Name && g.opponent != new.Name && new.Opponent != new.Name {
+			continue
+		}
+
+		s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+		return g
+	}
+	return nil
+}
+
+// prepareGame assigns the seats and plays the opening once both players joined
+func (s *Server) prepareGame(g *Game) {
+	if s.openings == nil {
+		if !s.seats.Assign(g.p1Name, g.p2Name) {
+			g.swapSeats()
+		}
+		return
+	}
+
+	opening, first, found := s.rematches.Take(g.p1Name, g.p2Name)
+	if !found {
+		var err error
+		opening, err = s.openings.next()
+		if err != nil {
+			log.Printf("game #%d starts from the empty board: %s", g.id, err)
+		}
+		first = g.p1Name
+		second := g.p2Name
+		if !s.seats.Assign(g.p1Name, g.p2Name) {
+			first, second = second, first
+		}
+		s.rematches.Add(first, second, opening)
+	}
+
+	if first != g.p1Name {
+		g.swapSeats()
+	}
+
+	for _, move := range opening {
+		g.board.Play(move)
+	}
+	g.opening = opening
+}
+
 func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult, error) {
 	//log.Printf("Server.Move(Id: %d, Move: %d)", a.Id, a.Move)
 
-	gameId := (a.Id - a.Id%2) / 2
-	//log.Printf("Looking for game #%d", gameId)
-
-	g, ok := s.games[gameId]
+	s.m.Lock()
+	g, ok := s.games[a.Id]
+	s.m.Unlock()
 	if !ok {
-		log.Printf("game #%d not found", gameId)
+		log.Printf("game of player #%d not found", a.Id)
 		return nil, errors.New("game not found")
 	}
 	//log.Printf("game found: %s", g)
 
 	if g.isOver() {
-		log.Printf("game #%d is already over", gameId)
+		log.Printf("game #%d is already over", g.id)
 		return nil, errors.New("game is already over")
 	}
 
 	isFirstPlayer := a.Id == g.p1
-	if (g.turn == 1 && !isFirstPlayer) || (g.turn == 2 && isFirstPlayer) {
+	if g.board.Turn() != g.seat(a.Id) {
 		log.Printf("player #%d tried to make a move, but it was not his turn", a.Id)
 		return nil, errors.New("it's not your turn")
 	}
 
-	if g.state[a.Move] != 0 {
-		//log.Printf("player #%d tried to make an invalid move, field already occupied (%d)", a.Id, a.Move)
-		return &proto.StateResult{
-			Id:       a.Id,
-			State:    mapOutput(g.state, isFirstPlayer),
-			Result:   proto.InvalidMove,
-			LastMove: g.lastMove,
-		}, nil
-	}
-
-	if g.lastMove > -1 {
-		subBoardStart := (g.lastMove % 9) * 9
-		subBoardEnd := subBoardStart + 9
-
-		subState := g.state[subBoardStart:subBoardEnd]
-		if !isStateOver(subState) && (a.Move < subBoardStart || a.Move >= subBoardEnd) {
-			//log.Printf("player #%d tried to make an invalid move, not in expected sub board %d-%d (%d)", a.Id, subBoardStart, subBoardEnd, a.Move)
-			return &proto.StateResult{
-				Id:       a.Id,
-				State:    mapOutput(g.state, isFirstPlayer),
-				Result:   proto.InvalidMove,
-				LastMove: g.lastMove,
-			}, nil
-		}
+	if a.Kind == proto.SwapAction {
+		return s.swap(g, a.Id), nil
 	}
 
-	targetBoardStart := a.Move - a.Move%9
-	targetBoardEnd := targetBoardStart + 9
-
-	targetBoardState := g.state[targetBoardStart:targetBoardEnd]
-	//log.Printf("%d...%d: %v", targetBoardStart, targetBoardEnd, targetBoardState)
-	if isStateOver(targetBoardState) {
-		//log.Printf("player #%d tried to make an invalid move, sub board already done (%d)", a.Id, a.Move)
+	if !g.board.IsValidMove(a.Move) {
+		//log.Printf("player #%d tried to make an invalid move (%d)", a.Id, a.Move)
 		return &proto.StateResult{
-			Id:       a.Id,
-			State:    mapOutput(g.state, isFirstPlayer),
-			Result:   proto.InvalidMove,
-			LastMove: g.lastMove,
+			Id:         a.Id,
+			Seat:       g.seat(a.Id),
+			State:      g.state(a.Id),
+			Result:     proto.InvalidMove,
+			LastMove:   g.board.LastMove(),
+			LegalMoves: g.board.ValidMoves(),
 		}, nil
 	}
 
-	g.state[a.Move] = g.turn
-	g.turn = 3 - g.turn
-	g.lastMove = a.Move
+	g.board.Play(a.Move)
+	g.moves = append(g.moves, a.Move)
 
 	//log.Printf("Game had received move: %d", a.Move)
 
 	if g.IsWon(a.Id) {
 		if isFirstPlayer {
-			s.stats[g.p1Name].won++
-			s.stats[g.p2Name].lost++
+			s.addResult(g, proto.Won)
 			//log.Printf("Game #%d is won by %s", gameId, g.p1Name)
 			g.Player1Done()
 		} else {
-			s.stats[g.p1Name].lost++
-			s.stats[g.p2Name].won++
+			s.addResult(g, proto.Lost)
 			//log.Printf("Game #%d is won by %s", gameId, g.p2Name)
 			g.Player2Done()
 		}
 
 		return &proto.StateResult{
 			Id:       a.Id,
-			State:    mapOutput(g.state, a.Id == g.p1),
+			Seat:     g.seat(a.Id),
+			State:    g.state(a.Id),
 			Result:   proto.Won,
-			LastMove: g.lastMove,
+			LastMove: g.board.LastMove(),
 		}, nil
 	}
 
@@ -222,33 +327,32 @@ func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult,
 		} else {
 			g.Player2Done()
 		}
-		s.stats[g.p1Name].draw++
-		s.stats[g.p2Name].draw++
+		s.addResult(g, proto.Draw)
 		return &proto.StateResult{
 			Id:       a.Id,
-			State:    mapOutput(g.state, a.Id == g.p1),
+			Seat:     g.seat(a.Id),
+			State:    g.state(a.Id),
 			Result:   proto.Draw,
-			LastMove: g.lastMove,
+			LastMove: g.board.LastMove(),
 		}, nil
 	}
 
 	if g.IsLost(a.Id) {
 		if isFirstPlayer {
-			s.stats[g.p1Name].lost++
-			s.stats[g.p2Name].won++
+			s.addResult(g, proto.Lost)
 			//log.Printf("Game #%d is lost by %s", gameId, g.p1Name)
 			g.Player1Done()
 		} else {
-			s.stats[g.p1Name].won++
-			s.stats[g.p2Name].lost++
+			s.addResult(g, proto.Won)
 			//log.Printf("Game #%d is lost by %s", gameId, g.p2Name)
 			g.Player2Done()
 		}
 		return &proto.StateResult{
 			Id:       a.Id,
-			State:    mapOutput(g.state, a.Id == g.p1),
+			Seat:     g.seat(a.Id),
+			State:    g.state(a.Id),
 			Result:   proto.Lost,
-			LastMove: g.lastMove,
+			LastMove: g.board.LastMove(),
 		}, nil
 	}
 
@@ -260,57 +364,102 @@ func (s *Server) Move(ctx context.Context, a *proto.Action) (*proto.StateResult,
 		g.WaitForPlayer1()
 	}
 
-	result := proto.ValidMove
-	if g.IsWon(a.Id) {
-		if isFirstPlayer {
-			//log.Printf("Game #%d is won by %s", gameId, g.p1Name)
-		} else {
-			//log.Printf("Game #%d is won by %s", gameId, g.p2Name)
-		}
-		result = proto.Won
-	} else if g.IsLost(a.Id) {
-		if isFirstPlayer {
-			//log.Printf("Game #%d is lost for %s", gameId, g.p1Name)
-		} else {
-			//log.Printf("Game #%d is lost for %s", gameId, g.p2Name)
-		}
-		result = proto.Lost
-	} else if g.IsDraw() {
-		//log.Printf("Game #%d is a draw", gameId)
-		result = proto.Draw
-	}
-
 	return &proto.StateResult{
-		Id:       a.Id,
-		State:    mapOutput(g.state, a.Id == g.p1),
-		Result:   result,
-		LastMove: g.lastMove,
+		Id:         a.Id,
+		Seat:       g.seat(a.Id),
+		State:      g.state(a.Id),
+		Result:     g.result(a.Id),
+		LastMove:   g.board.LastMove(),
+		LegalMoves: g.board.ValidMoves(),
 	}, nil
 }
 
-// map user numbers to me: 1 and -1: opponent
-func mapOutput(state []int64, p1Active bool) []int64 {
-	var mapping map[int64]int64
-	if p1Active {
-		mapping = map[int64]int64{0: 0, 1: 1, 2: -1}
-	} else {
-		mapping = map[int64]int64{0: 0, 1: -1, 2: 1}
+// swap lets the second player take over the position after the first move,
+// the players change seats and colors and the former first player moves next
+func (s *Server) swap(g *Game, pId int64) *proto.StateResult {
+	if !g.canSwap() {
+		//log.Printf("player #%d tried to swap, but the pie rule does not apply", pId)
+		return &proto.StateResult{
+			Id:         pId,
+			Seat:       g.seat(pId),
+			State:      g.state(pId),
+			Result:     proto.InvalidMove,
+			LastMove:   g.board.LastMove(),
+			LegalMoves: g.board.ValidMoves(),
+		}
 	}
 
-	out := make([]int64, len(state))
-	for i, v := range state {
-		out[i] = mapping[v]
+	g.swapSeats()
+	g.swapped = true
+
+	// the former first player is waiting for player 2, which is its own seat now
+	g.Player2Done()
+	g.WaitForPlayer2()
+
+	return &proto.StateResult{
+		Id:         pId,
+		Seat:       g.seat(pId),
+		State:      g.state(pId),
+		Result:     g.result(pId),
+		LastMove:   g.board.LastMove(),
+		LegalMoves: g.board.ValidMoves(),
 	}
-	return out
 }
 
 type Game struct {
+	id             int64
 	p1, p2         int64
 	p1Name, p2Name string
+	opponent       string // the only player the creator of the game wants to play
 	d1, d2         chan struct{}
-	state          []int64
-	lastMove       int64
-	turn           int64
+	ready          chan struct{} // closed once the seats are assigned
+	board          *board.Board  // player 1 is the seat of p1
+	opening        []int64
+	moves          []int64
+	swapped        bool // the second player took over the first move
+}
+
+func NewGame(rules *proto.Rules) *Game {
+	return &Game{
+		p2:    -1,
+		board: board.New(rules),
+		d1:    make(chan struct{}),
+		d2:    make(chan struct{}),
+		ready: make(chan struct{}),
+	}
+}
+
+func (g *Game) swapSeats() {
+	g.p1, g.p2 = g.p2, g.p1
+	g.p1Name, g.p2Name = g.p2Name, g.p1Name
+}
+
+// canSwap reports whether the pie rule allows the player to move to take over
+// the position instead, which is only the case right after the first move
+func (g Game) canSwap() bool {
+	return g.board.Rules().PieRule && !g.swapped && len(g.opening) == 0 && len(g.moves) == 1
+}
+
+// firstTurn returns the seat of the player making the first move after the
+// opening
+func (g Game) firstTurn() int64 {
+	return 1 + int64(len(g.opening)%2)
+}
+
+// state returns the fields as sent to a player, 1 are its own and -1 the
+// ones of the opponent
+func (g Game) state(pId int64) []int64 {
+	seat := g.seat(pId)
+	state := make([]int64, g.board.Size())
+	for i := range state {
+		switch g.board.Field(int64(i)) {
+		case seat:
+			state[i] = 1
+		case 3 - seat:
+			state[i] = -1
+		}
+	}
+	return state
 }
 
 func (g Game) String() string {
@@ -324,52 +473,20 @@ func (g Game) String() string {
 
 	return fmt.Sprintf(
 		"Game #%d: %d vs. %d turn: %d",
-		g.p1/2, g.p1, g.p2, g.turn,
+		g.id, g.p1, g.p2, g.board.Turn(),
 	)
 }
 
 func (g Game) IsWon(pId int64) bool {
-	var p int64
-	if g.p1 == pId {
-		p = 1
-	} else {
-		p = 2
-	}
-
-	subBoards := make([]int64, 9)
-	for i := 0; i < 9; i++ {
-		subBoards[i] = getSubResult(g.state[i*9 : i*9+9])
-	}
-
-	places := [][]int64{
-		{0, 1, 2},
-		{3, 4, 5},
-		{6, 7, 8},
-		{0, 4, 8},
-		{6, 4, 2},
-		{0, 3, 6},
-		{1, 4, 7},
-		{2, 5, 8},
-	}
-
-	for _, ps := range places {
-		if p == subBoards[ps[0]] && p == subBoards[ps[1]] && p == subBoards[ps[2]] {
-			return true
-		}
-	}
-
-	wonBoards := []int64{0, 0, 0}
-	for _, result := range subBoards {
-		if result == 0 {
-			return false
-		}
+	return g.board.IsWon(g.seat(pId))
+}
 
-		if result > 0 {
-			wonBoards[result]++
-		}
+// seat returns 1 if the player moves first and 2 otherwise
+func (g Game) seat(pId int64) int64 {
+	if pId == g.p1 {
+		return 1
 	}
-
-	return wonBoards[p] > wonBoards[3-p]
+	return 2
 }
 
 func (g Game) IsLost(p int64) bool {
@@ -381,58 +498,24 @@ func (g Game) IsLost(p int64) bool {
 }
 
 func (g Game) IsDraw() bool {
-	subBoards := make([]int64, 9)
-	for i := 0; i < 9; i++ {
-		subBoards[i] = getSubResult(g.state[i*9 : i*9+9])
-	}
-
-	wonBoards := []int64{0, 0, 0}
-	for _, result := range subBoards {
-		if result == 0 {
-			return false
-		}
-
-		if result > 0 {
-			wonBoards[result]++
-		}
-	}
-
-	return wonBoards[1] == wonBoards[2]
-}
-
-func isStateOver(state []int64) bool {
-	return getSubResult(state) != 0
+	return g.board.Result() == board.Draw
 }
 
-func getSubResult(state []int64) int64 {
-	places := [][]int64{
-		{0, 1, 2},
-		{3, 4, 5},
-		{6, 7, 8},
-		{0, 4, 8},
-		{6, 4, 2},
-		{0, 3, 6},
-		{1, 4, 7},
-		{2, 5, 8},
-	}
-
-	for _, ps := range places {
-		if 0 != state[ps[0]] && state[ps[0]] == state[ps[1]] && state[ps[1]] == state[ps[2]] {
-			return state[ps[0]]
-		}
-	}
-
-	for _, v := range state {
-		if v == 0 {
-			return 0 // unfinished
-		}
-	}
-
-	return -1 // draw
+// result returns the result of the game from the view of a player
+func (g Game) result(pId int64) int64 {
+	switch {
+	case g.IsWon(pId):
+		return proto.Won
+	case g.IsLost(pId):
+		return proto.Lost
+	case g.IsDraw():
+		return proto.Draw
+	}
+	return proto.ValidMove
 }
 
 func (g Game) isOver() bool {
-	return g.IsWon(g.p1) || g.IsWon(g.p2) || g.IsDraw()
+	return g.board.Result() != board.Unfinished
 }
 
 func (g Game) Player1Done() {
@@ -451,8 +534,157 @@ func (g Game) WaitForPlayer2() {
 	<-g.d2
 }
 
+// Openings provides the positions games start from, either from a book or
+// generated by random moves
+type Openings struct {
+	book  [][]int64
+	moves int
+	rules *proto.Rules
+}
+
+// LoadOpenings reads a file with the moves of an opening per line, separated
+// by spaces or commas
+func LoadOpenings(path string, rules *proto.Rules) (*Openings, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Openings{rules: rules}
+	for i, line := range strings.Split(string(raw), "\n") {
+		fields := strings.FieldsFunc(line, func(c rune) bool {
+			return c == ',' || unicode.IsSpace(c)
+		})
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		opening := make([]int64, len(fields))
+		for j, field := range fields {
+			opening[j], err = strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+		}
+
+		if !o.isBalanced(opening) {
+			return nil, fmt.Errorf("line %d: opening contains invalid moves or decides the game", i+1)
+		}
+		o.book = append(o.book, opening)
+	}
+
+	if len(o.book) == 0 {
+		return nil, errors.New("no openings found")
+	}
+	return o, nil
+}
+
+// isBalanced checks that the moves of an opening are valid and do not decide
+// the game already
+func (o *Openings) isBalanced(opening []int64) bool {
+	b := board.New(o.rules)
+	for _, move := range opening {
+		if b.Result() != board.Unfinished || !b.IsValidMove(move) {
+			return false
+		}
+		b.Play(move)
+	}
+	return b.Result() == board.Unfinished
+}
+
+// openingTries is the number of random openings drawn before giving up on
+// finding one that leaves the game undecided
+const openingTries = 1000
+
+// next returns an opening of the book or a random one, random openings are
+// drawn again until one leaves the game undecided
+func (o *Openings) next() ([]int64, error) {
+	if len(o.book) > 0 {
+		return o.book[r.Intn(len(o.book))], nil
+	}
+
+	for i := 0; i < openingTries; i++ {
+		b := board.New(o.rules)
+		opening := make([]int64, 0, o.moves)
+		for len(opening) < o.moves && b.Result() == board.Unfinished {
+			moves := b.ValidMoves()
+			move := moves[r.Intn(len(moves))]
+			b.Play(move)
+			opening = append(opening, move)
+		}
+
+		if len(opening) == o.moves && b.Result() == board.Unfinished {
+			return opening, nil
+		}
+	}
+	return nil, fmt.Errorf("no undecided opening of %d moves found in %d tries", o.moves, openingTries)
+}
+
 type Stats struct {
 	won  int64
 	lost int64
 	draw int64
 }
+
+func (st Stats) String() string {
+	return fmt.Sprintf(
+		"%.0f%% (%d / %d / %d)",
+		float64(st.won)/float64(st.won+st.draw+st.lost)*100,
+		st.won,
+		st.draw,
+		st.lost,
+	)
+}
+
+func (st *Stats) add(result int64) {
+	switch result {
+	case proto.Won:
+		st.won++
+	case proto.Lost:
+		st.lost++
+	case proto.Draw:
+		st.draw++
+	}
+}
+
+type PlayerStats struct {
+	Stats
+	seats [2]Stats // results when moving first and second
+}
+
+func (ps *PlayerStats) add(seat, result int64) {
+	ps.Stats.add(result)
+	ps.seats[seat-1].add(result)
+}
+
+// addResult counts and records a finished game, the result is from the view
+// of player 1
+func (s *Server) addResult(g *Game, result int64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.records != nil {
+		err := s.records.Write(record.Record{
+			GameType: proto.UltimateTicTacToe,
+			Players:  [2]string{g.p1Name, g.p2Name},
+			Rules:    g.board.Rules(),
+			Opening:  g.opening,
+			Moves:    g.moves,
+			Swapped:  g.swapped,
+			Result:   result,
+		})
+		if err != nil {
+			log.Printf("unable to record game: %s", err)
+		}
+	}
+
+	s.stats[g.p1Name].add(1, result)
+	switch result {
+	case proto.Won:
+		s.stats[g.p2Name].add(2, proto.Lost)
+	case proto.Lost:
+		s.stats[g.p2Name].add(2, proto.Won)
+	default:
+		s.stats[g.p2Name].add(2, result)
+	}
+}